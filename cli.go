@@ -0,0 +1,639 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"iOSGhostRun/services"
+)
+
+// cliCommand 无界面模式子命令
+type cliCommand struct {
+	summary string
+	run     func(args []string) error
+}
+
+// cliCommands 无界面模式支持的子命令，命中任意一个时不启动窗口
+var cliCommands = map[string]cliCommand{
+	"devices":      {"列出已连接的设备", cmdDevices},
+	"select":       {"选择设备：启动隧道并挂载开发者镜像，依附守护进程时由它保持", cmdSelect},
+	"run":          {"按路线模拟跑步，Ctrl-C 停止并恢复真实位置", cmdRun},
+	"set-location": {"将设备定位到指定坐标", cmdSetLocation},
+	"reset":        {"恢复设备真实位置", cmdReset},
+	"daemon":       {"以守护进程方式运行，保持隧道和位置会话", cmdDaemon},
+}
+
+// errUsage 参数错误，已由 flag 包输出用法
+var errUsage = errors.New("usage")
+
+// runCLI 执行子命令并返回进程退出码
+func runCLI(name string, args []string) int {
+	cmd, ok := cliCommands[name]
+	if !ok {
+		printCLIUsage(os.Stderr)
+		return 2
+	}
+
+	if err := cmd.run(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		return 1
+	}
+	return 0
+}
+
+func printCLIUsage(w io.Writer) {
+	names := make([]string, 0, len(cliCommands))
+	for name := range cliCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "用法: iOSGhostRun <命令> [参数]")
+	fmt.Fprintln(w, "不带命令时启动图形界面。可用命令:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-14s %s\n", name, cliCommands[name].summary)
+	}
+}
+
+// headless 无界面模式下的服务集合
+type headless struct {
+	bus      *services.EventBus
+	devices  *services.DevicesService
+	location *services.LocationService
+	running  *services.RunningService
+	daemon   *services.DaemonClient
+}
+
+// newHeadless 创建服务集合；守护进程在运行且未指定 standalone 时，
+// 设备选择和位置下发交给守护进程，由它保持隧道和位置会话
+func newHeadless(standalone bool) *headless {
+	bus := services.NewEventBus()
+	services.NewLoggerService(bus)
+
+	h := &headless{bus: bus}
+	if !standalone {
+		if client, err := services.DialDaemon(services.DefaultDaemonSocket()); err == nil {
+			h.daemon = client
+		}
+	}
+
+	if h.daemon != nil {
+		h.devices = services.NewDevicesServiceWithDaemon(bus, h.daemon)
+		h.location = services.NewLocationServiceWithBackend(h.daemon)
+	} else {
+		h.devices = services.NewDevicesService(bus)
+		h.location = services.NewLocationService()
+	}
+	h.running = services.NewRunningService(h.location, bus)
+	return h
+}
+
+// close 停止跑步、释放位置会话并关闭隧道，依附守护进程时只断开连接
+func (h *headless) close() {
+	h.running.StopRun()
+	_ = h.location.Close()
+	if h.daemon == nil {
+		_ = services.StopTunnel()
+	}
+}
+
+// resolveUDID 未指定 udid 时，使用唯一连接的设备
+func (h *headless) resolveUDID(udid string) (string, error) {
+	if udid != "" {
+		return udid, nil
+	}
+	list, err := h.devices.ListDevices()
+	if err != nil {
+		return "", err
+	}
+	switch len(list) {
+	case 0:
+		return "", fmt.Errorf("没有可连接的设备")
+	case 1:
+		return list[0].UDID, nil
+	default:
+		return "", fmt.Errorf("连接了 %d 台设备，请使用 --udid 指定", len(list))
+	}
+}
+
+// cliOutput 输出结果，json 模式下每个结果为一行 JSON
+type cliOutput struct {
+	json bool
+	w    io.Writer
+}
+
+func (o cliOutput) print(v any, text string) {
+	if o.json {
+		_ = json.NewEncoder(o.w).Encode(v)
+		return
+	}
+	fmt.Fprintln(o.w, text)
+}
+
+// commonFlags 各子命令通用的参数
+type commonFlags struct {
+	json       bool
+	standalone bool
+}
+
+// newFlagSet 创建子命令参数集，并附带通用的 --json、--standalone 参数
+func newFlagSet(name string) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	common := &commonFlags{}
+	fs.BoolVar(&common.json, "json", false, "以 JSON 输出结果")
+	fs.BoolVar(&common.standalone, "standalone", false, "不使用已运行的守护进程")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: iOSGhostRun %s [参数]\n", name)
+		fs.PrintDefaults()
+	}
+	return fs, common
+}
+
+// signalContext 在收到 Ctrl-C 或 SIGTERM 时取消
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+func cmdDevices(args []string) error {
+	fs, common := newFlagSet("devices")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	h := newHeadless(common.standalone)
+	defer h.close()
+
+	list, err := h.devices.ListDevices()
+	if err != nil {
+		return err
+	}
+
+	out := cliOutput{json: common.json, w: os.Stdout}
+	if out.json {
+		out.print(list, "")
+		return nil
+	}
+	for _, d := range list {
+		out.print(d, fmt.Sprintf("%s\t%s\t%s\tiOS %s", d.UDID, d.DeviceName, d.ProductType, d.ProductVersion))
+	}
+	return nil
+}
+
+func cmdSelect(args []string) error {
+	fs, common := newFlagSet("select")
+	udid := fs.String("udid", "", "设备 UDID，只连接一台设备时可省略")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	h := newHeadless(common.standalone)
+	defer h.close()
+
+	id, err := h.resolveUDID(*udid)
+	if err != nil {
+		return err
+	}
+	if err := h.devices.SelectDevice(id); err != nil {
+		return err
+	}
+	info, err := h.devices.GetSelectedDevice()
+	if err != nil {
+		return err
+	}
+
+	// 不依附守护进程时隧道随本进程退出而关闭，只用于检查设备能否完成隧道和镜像挂载
+	result := struct {
+		*services.DeviceInfo
+		Persistent bool `json:"persistent"`
+	}{info, h.daemon != nil}
+	text := fmt.Sprintf("已选择设备 %s (%s, iOS %s)，开发者镜像已挂载", info.UDID, info.DeviceName, info.ProductVersion)
+	if h.daemon == nil {
+		text += "；未使用守护进程，隧道随本命令退出而关闭，其他命令会自行连接设备"
+	}
+	cliOutput{json: common.json, w: os.Stdout}.print(result, text)
+	return nil
+}
+
+func cmdRun(args []string) error {
+	fs, common := newFlagSet("run")
+	udid := fs.String("udid", "", "设备 UDID，只连接一台设备时可省略")
+	routePath := fs.String("route", "", "路线文件 (.ghostroute/.json/.gpx/.kml/.kmz/.geojson/.csv)")
+	speed := fs.Float64("speed", 8, "速度 km/h")
+	loops := fs.Int("loops", 1, "循环圈数，0 为无限")
+	variance := fs.Float64("variance", 1, "速度变化范围 km/h")
+	offset := fs.Float64("offset", 3, "垂直于前进方向的随机偏移幅度（米）")
+	offsetBias := fs.Float64("offset-bias", 0, "固定横向偏移（米），正值靠前进方向右侧，负值靠左侧")
+	every := fs.Duration("progress", time.Second, "进度输出间隔")
+	trackPath := fs.String("track", "", "跑步结束后将实际轨迹保存到该文件 (.geojson/.fit/.tcx)")
+	coord := fs.String("coord", "WGS84", "路线坐标系 (WGS84/GCJ02/BD09)")
+	simplify := fs.Float64("simplify", 0, "按该容差（米）简化路线，0 为不简化")
+	resample := fs.Float64("resample", 0, "按该间距（米）重采样路线，在简化之后执行，0 为不重采样")
+	noiseSpec := fs.String("noise", "", "叠加的 GPS 噪声，如 \"ou:sigma=2,tau=20;multipath:sigma=15,rate=0.5\"（gaussian/ou/multipath）")
+	seed := fs.Int64("seed", 0, "随机种子，0 为随机；传入上次输出的种子可复现同一条轨迹")
+	smooth := fs.String("smooth", "none", "轨迹平滑方式 (none/arc/spline)")
+	turnRadius := fs.Float64("turn-radius", 3, "arc 平滑的转弯半径（米）")
+	finish := fs.Duration("finish", 0, "目标完成时间（如 45m），从开始跑步算起，含暂停；设置后忽略 --speed，按路线长度乘以圈数配速")
+	goalDistance := fs.Float64("distance", 0, "跑满该距离（km）后结束，可在一圈中途结束，0 为不限")
+	goalDuration := fs.Duration("duration", 0, "跑满该时间（如 30m，不含暂停）后结束，0 为不限")
+	activity := fs.String("activity", "none", "加减速与过弯模型 (none/walk/run/cycle/drive)")
+	workoutPath := fs.String("workout", "", "间歇训练文件 (.json)，设置后按训练阶段配速并在全部阶段结束时完成")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *routePath == "" {
+		fmt.Fprintln(fs.Output(), "缺少 --route")
+		fs.Usage()
+		return errUsage
+	}
+
+	if *trackPath != "" {
+		if _, err := trackWriter(*trackPath); err != nil {
+			return err
+		}
+	}
+	cs, err := services.ParseCoordSystem(*coord)
+	if err != nil {
+		return err
+	}
+	smoothing, err := services.ParseSmoothingMode(*smooth)
+	if err != nil {
+		return err
+	}
+	noiseLayers, err := services.ParseGPSNoise(*noiseSpec)
+	if err != nil {
+		return err
+	}
+	profile, err := services.MotionProfilePreset(*activity)
+	if err != nil {
+		return err
+	}
+	route, err := services.LoadRouteFile(*routePath)
+	if err != nil {
+		return err
+	}
+	var workout *services.Workout
+	if *workoutPath != "" {
+		if workout, err = loadWorkout(*workoutPath); err != nil {
+			return err
+		}
+	}
+	if err := applyRouteDefaults(fs, *routePath, speed, variance, offset, loops); err != nil {
+		return err
+	}
+	goal := services.RunGoal{Distance: *goalDistance, Duration: goalDuration.Seconds()}
+	if goal != (services.RunGoal{}) && !flagSet(fs, "loops") {
+		// 只指定距离或时间目标时不限圈数，由目标结束跑步
+		*loops = 0
+	}
+
+	out := cliOutput{json: common.json, w: os.Stdout}
+	if *simplify > 0 {
+		var stats services.RouteEditStats
+		route, stats = services.SimplifyRoute(route, *simplify)
+		out.print(cliEvent{Event: "route:simplified", Route: &stats}, "已简化路线，"+stats.String())
+	}
+	if *resample > 0 {
+		var stats services.RouteEditStats
+		route, stats = services.ResampleRoute(route, *resample)
+		out.print(cliEvent{Event: "route:resampled", Route: &stats}, "已重采样路线，"+stats.String())
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	h := newHeadless(common.standalone)
+	defer h.close()
+
+	id, err := h.resolveUDID(*udid)
+	if err != nil {
+		return err
+	}
+	if err := h.devices.SelectDevice(id); err != nil {
+		return err
+	}
+
+	done := make(chan services.RunningStatus, 1)
+	aborted := make(chan string, 1)
+	var lastPrint time.Time
+	unsubscribe := h.bus.Subscribe(func(name string, data any) {
+		switch name {
+		case services.EventRunningStarted:
+			status := data.(services.RunningStatus)
+			out.print(cliEvent{Event: name, Status: &status}, fmt.Sprintf("开始跑步，随机种子 %d", status.Seed))
+		case services.EventRunningPosition:
+			status := data.(services.RunningStatus)
+			if time.Since(lastPrint) < *every {
+				return
+			}
+			lastPrint = time.Now()
+			out.print(cliEvent{Event: name, Status: &status}, formatRunStatus(status, *loops))
+		case services.EventRunningInterval:
+			interval := data.(services.IntervalStatus)
+			out.print(cliEvent{Event: name, Interval: &interval}, formatInterval(interval))
+		case services.EventRunningError:
+			msg := fmt.Sprint(data)
+			out.print(cliEvent{Event: name, Error: msg}, "错误: "+msg)
+		case services.EventRunningCompleted:
+			select {
+			case done <- data.(services.RunningStatus):
+			default:
+			}
+		case services.EventRunningAborted:
+			select {
+			case aborted <- fmt.Sprint(data):
+			default:
+			}
+		}
+	}, services.EventRunningStarted, services.EventRunningPosition, services.EventRunningInterval, services.EventRunningError, services.EventRunningCompleted, services.EventRunningAborted)
+	defer unsubscribe()
+
+	h.running.SetLoopCount(*loops)
+	h.running.SetRandomization(*variance, *offset)
+	h.running.SetOffsetBias(*offsetBias)
+	if err := h.running.SetCoordSystem(cs); err != nil {
+		return err
+	}
+	if err := h.running.SetGPSNoise(services.GPSNoiseConfig{Layers: noiseLayers}); err != nil {
+		return err
+	}
+	h.running.SetSeed(*seed)
+	if err := h.running.SetWorkout(workout); err != nil {
+		return err
+	}
+	if err := h.running.SetTargetFinish(*finish); err != nil {
+		return err
+	}
+	if err := h.running.SetRunGoal(goal); err != nil {
+		return err
+	}
+	if err := h.running.SetMotionProfile(profile); err != nil {
+		return err
+	}
+	if err := h.running.SetSmoothing(smoothing, *turnRadius); err != nil {
+		return err
+	}
+	if err := h.running.StartRun(id, route, *speed); err != nil {
+		return err
+	}
+
+	select {
+	case status := <-done:
+		out.print(cliEvent{Event: services.EventRunningCompleted, Status: &status}, fmt.Sprintf("跑步完成（%s），%s", endReasonText(status.CompletedBy), formatRunStatus(status, *loops)))
+	case <-ctx.Done():
+		status := h.running.GetStatus()
+		out.print(cliEvent{Event: "running:stopped", Status: &status}, "已停止，"+formatRunStatus(status, *loops))
+	case msg := <-aborted:
+		// 已下发的轨迹仍然保存，再报告终止原因
+		if *trackPath != "" {
+			if err := saveTrack(*trackPath, h.running.GetTrack()); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("跑步已终止: %s", msg)
+	}
+
+	if *trackPath != "" {
+		return saveTrack(*trackPath, h.running.GetTrack())
+	}
+	return nil
+}
+
+// loadWorkout 读取 JSON 格式的间歇训练
+func loadWorkout(path string) (*services.Workout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取训练文件失败: %w", err)
+	}
+	var workout services.Workout
+	if err := json.Unmarshal(data, &workout); err != nil {
+		return nil, fmt.Errorf("解析训练文件失败: %w", err)
+	}
+	if err := workout.Validate(); err != nil {
+		return nil, err
+	}
+	return &workout, nil
+}
+
+// applyRouteDefaults 使用 .ghostroute 文件中的默认跑步参数，命令行显式指定的参数优先
+func applyRouteDefaults(fs *flag.FlagSet, path string, speed, variance, offset *float64, loops *int) error {
+	if !strings.EqualFold(filepath.Ext(path), services.GhostRouteExt) {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取路线文件失败: %w", err)
+	}
+	gr, err := services.ParseGhostRoute(data)
+	if err != nil || gr.Defaults == nil {
+		return err
+	}
+
+	d := gr.Defaults
+	if d.Speed > 0 && !flagSet(fs, "speed") {
+		*speed = d.Speed
+	}
+	if d.SpeedVariance > 0 && !flagSet(fs, "variance") {
+		*variance = d.SpeedVariance
+	}
+	if d.RouteOffset > 0 && !flagSet(fs, "offset") {
+		*offset = d.RouteOffset
+	}
+	if d.LoopCount > 0 && !flagSet(fs, "loops") {
+		*loops = d.LoopCount
+	}
+	return nil
+}
+
+// flagSet 参数是否在命令行中显式指定
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// trackWriter 按扩展名选择轨迹格式，运行前即可检查 --track 是否受支持
+func trackWriter(path string) (func(w io.Writer, track []services.TrackPoint) error, error) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".geojson":
+		return func(w io.Writer, track []services.TrackPoint) error {
+			return services.WriteTrackGeoJSON(w, name, track)
+		}, nil
+	case ".fit":
+		return services.WriteTrackFIT, nil
+	case ".tcx":
+		return func(w io.Writer, track []services.TrackPoint) error { return services.WriteTrackTCX(w, name, track) }, nil
+	default:
+		return nil, fmt.Errorf("不支持的轨迹文件格式: %s", ext)
+	}
+}
+
+// saveTrack 按扩展名保存跑步轨迹
+func saveTrack(path string, track []services.TrackPoint) error {
+	write, err := trackWriter(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建轨迹文件失败: %w", err)
+	}
+	if err := write(f, track); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// cliEvent json 模式下输出的跑步事件
+type cliEvent struct {
+	Event    string                   `json:"event"`
+	Status   *services.RunningStatus  `json:"status,omitempty"`
+	Error    string                   `json:"error,omitempty"`
+	Route    *services.RouteEditStats `json:"route,omitempty"`
+	Interval *services.IntervalStatus `json:"interval,omitempty"`
+}
+
+func endReasonText(reason services.RunEndReason) string {
+	switch reason {
+	case services.EndByDistance:
+		return "达到目标距离"
+	case services.EndByDuration:
+		return "达到目标时间"
+	case services.EndByWorkout:
+		return "训练结束"
+	default:
+		return "跑完全部圈数"
+	}
+}
+
+func formatInterval(i services.IntervalStatus) string {
+	target := fmt.Sprintf("%.0f 米", i.Distance)
+	if i.Duration > 0 {
+		target = (time.Duration(i.Duration) * time.Second).String()
+	}
+	return fmt.Sprintf("训练阶段 %d/%d：%s，%s，速度 %.1f km/h", i.Index+1, i.Total, i.Name(), target, i.Speed)
+}
+
+func formatRunStatus(s services.RunningStatus, loops int) string {
+	elapsed := time.Duration(s.ElapsedTimeMs) * time.Millisecond
+	loopText := fmt.Sprintf("%d/%d", s.CurrentLoop, loops)
+	if loops == 0 {
+		loopText = fmt.Sprintf("%d/∞", s.CurrentLoop)
+	}
+	return fmt.Sprintf("距离 %.2f km，速度 %.1f km/h，圈数 %s，用时 %s，位置 (%.6f, %.6f)",
+		s.Distance, s.Speed, loopText, elapsed.Truncate(time.Second), s.CurrentLat, s.CurrentLon)
+}
+
+func cmdSetLocation(args []string) error {
+	fs, common := newFlagSet("set-location")
+	udid := fs.String("udid", "", "设备 UDID，只连接一台设备时可省略")
+	lat := fs.Float64("lat", 0, "纬度")
+	lon := fs.Float64("lon", 0, "经度")
+	coord := fs.String("coord", "WGS84", "坐标系 (WGS84/GCJ02/BD09)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cs, err := services.ParseCoordSystem(*coord)
+	if err != nil {
+		return err
+	}
+	*lat, *lon = services.TransformCoordinate(*lat, *lon, cs, services.CoordWGS84)
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	h := newHeadless(common.standalone)
+	defer h.close()
+
+	id, err := h.resolveUDID(*udid)
+	if err != nil {
+		return err
+	}
+	if err := h.devices.SelectDevice(id); err != nil {
+		return err
+	}
+	if err := h.location.SetLocation(id, *lat, *lon); err != nil {
+		return err
+	}
+
+	out := cliOutput{json: common.json, w: os.Stdout}
+	point := services.Point{Lat: *lat, Lon: *lon}
+	out.print(point, fmt.Sprintf("已将设备 %s 定位到 (%.6f, %.6f)", id, *lat, *lon))
+
+	// iOS 17+ 的位置模拟随会话关闭而失效，未依附守护进程时需要保持进程运行
+	if h.daemon == nil && services.IsVersionAbove17(id) {
+		if !out.json {
+			fmt.Fprintln(os.Stderr, "iOS 17+ 需要保持连接，按 Ctrl-C 结束并恢复真实位置")
+		}
+		<-ctx.Done()
+		return h.location.ResetLocation(id)
+	}
+	return nil
+}
+
+func cmdReset(args []string) error {
+	fs, common := newFlagSet("reset")
+	udid := fs.String("udid", "", "设备 UDID，只连接一台设备时可省略")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	h := newHeadless(common.standalone)
+	defer h.close()
+
+	id, err := h.resolveUDID(*udid)
+	if err != nil {
+		return err
+	}
+	// iOS 17+ 的位置模拟由设置位置的进程持有，新进程中没有可停止的会话
+	if h.daemon == nil && services.IsVersionAbove17(id) {
+		return fmt.Errorf("iOS 17+ 设备需要通过守护进程重置位置；独立运行的 set-location/run 退出时已自动恢复真实位置")
+	}
+	if err := h.location.ResetLocation(id); err != nil {
+		return err
+	}
+
+	cliOutput{json: common.json, w: os.Stdout}.print(map[string]string{"udid": id}, fmt.Sprintf("设备 %s 位置已重置", id))
+	return nil
+}
+
+func cmdDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	socket := fs.String("socket", services.DefaultDaemonSocket(), "控制套接字路径")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	bus := services.NewEventBus()
+	services.NewLoggerService(bus)
+	devices := services.NewDevicesService(bus)
+	location := services.NewLocationService()
+	defer func() {
+		_ = location.Close()
+		_ = services.StopTunnel()
+	}()
+
+	return services.NewDaemonServer(devices, location, bus).Serve(ctx, *socket)
+}
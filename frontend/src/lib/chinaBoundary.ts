@@ -0,0 +1,73 @@
+// 中国大陆边界，与后端 services/china_boundary.go 使用同一份数据，地图显示与后端转换在边境处保持一致
+import boundaryGeoJSON from '../../../services/data/china_boundary.geojson?raw'
+
+// 纬度分带高度（度），与后端一致
+const bandHeight = 0.05
+
+// 多边形的一条边 [lat1, lon1, lat2, lon2]
+type Edge = [number, number, number, number]
+
+interface BoundaryIndex {
+    minLat: number
+    maxLat: number
+    minLon: number
+    maxLon: number
+    bands: Edge[][]
+}
+
+let index: BoundaryIndex | null = null
+
+// 解析边界并按纬度分带建立边索引，首次使用时执行
+function buildIndex(): BoundaryIndex {
+    const root = JSON.parse(boundaryGeoJSON)
+    const geometry = root.type === 'Feature' ? root.geometry : root
+    const polygons: number[][][][] = geometry.type === 'Polygon' ? [geometry.coordinates] : geometry.coordinates
+
+    const idx: BoundaryIndex = { minLat: Infinity, maxLat: -Infinity, minLon: Infinity, maxLon: -Infinity, bands: [] }
+    const edges: Edge[] = []
+    for (const rings of polygons) {
+        for (const ring of rings) {
+            for (let i = 0; i < ring.length; i++) {
+                const a = ring[i]
+                const b = ring[(i + 1) % ring.length]
+                idx.minLat = Math.min(idx.minLat, a[1])
+                idx.maxLat = Math.max(idx.maxLat, a[1])
+                idx.minLon = Math.min(idx.minLon, a[0])
+                idx.maxLon = Math.max(idx.maxLon, a[0])
+                if (a[1] !== b[1]) {
+                    edges.push([a[1], a[0], b[1], b[0]])
+                }
+            }
+        }
+    }
+
+    const band = (lat: number) => Math.floor((lat - idx.minLat) / bandHeight)
+    idx.bands = Array.from({ length: band(idx.maxLat) + 1 }, () => [])
+    for (const e of edges) {
+        const hi = band(Math.max(e[0], e[2]))
+        for (let b = band(Math.min(e[0], e[2])); b <= hi; b++) {
+            idx.bands[b].push(e)
+        }
+    }
+    return idx
+}
+
+// 点是否位于中国大陆边界内，向东发射水平射线，穿过奇数条边时点在多边形内
+export function inChina(lat: number, lon: number): boolean {
+    const idx = (index ??= buildIndex())
+    if (lat < idx.minLat || lat > idx.maxLat || lon < idx.minLon || lon > idx.maxLon) {
+        return false
+    }
+
+    let inside = false
+    for (const [lat1, lon1, lat2, lon2] of idx.bands[Math.floor((lat - idx.minLat) / bandHeight)]) {
+        if (lat1 > lat === lat2 > lat) {
+            continue
+        }
+        const crossLon = lon1 + ((lat - lat1) * (lon2 - lon1)) / (lat2 - lat1)
+        if (lon < crossLon) {
+            inside = !inside
+        }
+    }
+    return inside
+}
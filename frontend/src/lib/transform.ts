@@ -1,9 +1,16 @@
-// 坐标系转换常量
+import { inChina } from './chinaBoundary'
+
+// 坐标系转换常量，与后端 services/coordinate.go 一致
 const xPi = (Math.PI * 3000.0) / 180.0
 const pi = Math.PI
 const a = 6378245.0 // 长半轴
 const ee = 0.00669342162296594323 // 扁率
 
+// 迭代反解的收敛阈值（度），约 0.1 mm
+const inverseTolerance = 1e-9
+// 迭代反解的次数上限
+const inverseMaxIterations = 20
+
 // 坐标系类型
 export enum CoordSystem {
     WGS84 = 'WGS84', // GPS/iOS
@@ -17,15 +24,13 @@ export interface Coordinate {
     Longitude: number
 }
 
-// BD09 -> GCJ02
+// BD09 -> GCJ02，迭代反解 GCJ02ToBD09
 export function BD09ToGCJ02(bdLat: number, bdLon: number): [number, number] {
     const x = bdLon - 0.0065
     const y = bdLat - 0.006
     const z = Math.sqrt(x * x + y * y) - 0.00002 * Math.sin(y * xPi)
     const theta = Math.atan2(y, x) - 0.000003 * Math.cos(x * xPi)
-    const gcjLon = z * Math.cos(theta)
-    const gcjLat = z * Math.sin(theta)
-    return [gcjLat, gcjLon]
+    return invertCoordinate(bdLat, bdLon, z * Math.sin(theta), z * Math.cos(theta), GCJ02ToBD09)
 }
 
 // GCJ02 -> BD09
@@ -37,22 +42,17 @@ export function GCJ02ToBD09(gcjLat: number, gcjLon: number): [number, number] {
     return [bdLat, bdLon]
 }
 
-// GCJ02 -> WGS84
+// GCJ02 -> WGS84，与后端 GCJ02ToWGS84 一致
+//
+// 以一步近似为初值迭代反解 WGS84ToGCJ02，结果再正向转换后与输入的误差小于 inverseTolerance。
+// 是否偏移按反解得到的 WGS84 点判断：反解落在边界外时正向转换不偏移，原样返回输入。
 export function GCJ02ToWGS84(gcjLat: number, gcjLon: number): [number, number] {
-    if (outOfChina(gcjLat, gcjLon)) {
+    const [dLat, dLon] = gcjDelta(gcjLat, gcjLon)
+    const [wgsLat, wgsLon] = invertCoordinate(gcjLat, gcjLon, gcjLat - dLat, gcjLon - dLon, gcjOffset)
+    if (outOfChina(wgsLat, wgsLon)) {
         return [gcjLat, gcjLon]
     }
-    let dLat = transformLat(gcjLon - 105.0, gcjLat - 35.0)
-    let dLon = transformLon(gcjLon - 105.0, gcjLat - 35.0)
-    const radLat = (gcjLat / 180.0) * pi
-    let magic = Math.sin(radLat)
-    magic = 1 - ee * magic * magic
-    const sqrtMagic = Math.sqrt(magic)
-    dLat = (dLat * 180.0) / (((a * (1 - ee)) / (magic * sqrtMagic)) * pi)
-    dLon = (dLon * 180.0) / ((a / sqrtMagic) * Math.cos(radLat) * pi)
-    const mgLat = gcjLat + dLat
-    const mgLon = gcjLon + dLon
-    return [gcjLat * 2 - mgLat, gcjLon * 2 - mgLon]
+    return [wgsLat, wgsLon]
 }
 
 // WGS84 -> GCJ02
@@ -60,17 +60,7 @@ export function WGS84ToGCJ02(wgsLat: number, wgsLon: number): [number, number] {
     if (outOfChina(wgsLat, wgsLon)) {
         return [wgsLat, wgsLon]
     }
-    let dLat = transformLat(wgsLon - 105.0, wgsLat - 35.0)
-    let dLon = transformLon(wgsLon - 105.0, wgsLat - 35.0)
-    const radLat = (wgsLat / 180.0) * pi
-    let magic = Math.sin(radLat)
-    magic = 1 - ee * magic * magic
-    const sqrtMagic = Math.sqrt(magic)
-    dLat = (dLat * 180.0) / (((a * (1 - ee)) / (magic * sqrtMagic)) * pi)
-    dLon = (dLon * 180.0) / ((a / sqrtMagic) * Math.cos(radLat) * pi)
-    const mgLat = wgsLat + dLat
-    const mgLon = wgsLon + dLon
-    return [mgLat, mgLon]
+    return gcjOffset(wgsLat, wgsLon)
 }
 
 // BD09 -> WGS84
@@ -132,9 +122,49 @@ export function TransformCoordinates(coords: Coordinate[], from: CoordSystem, to
     return coords.map(c => TransformCoordinatePoint(c, from, to))
 }
 
-// 是否在中国境内
+// 从初值 (lat, lon) 出发，用不动点迭代求 forward 在 (targetLat, targetLon) 处的反解
+function invertCoordinate(
+    targetLat: number,
+    targetLon: number,
+    lat: number,
+    lon: number,
+    forward: (lat: number, lon: number) => [number, number]
+): [number, number] {
+    for (let i = 0; i < inverseMaxIterations; i++) {
+        const [fLat, fLon] = forward(lat, lon)
+        const dLat = targetLat - fLat
+        const dLon = targetLon - fLon
+        lat += dLat
+        lon += dLon
+        if (Math.abs(dLat) < inverseTolerance && Math.abs(dLon) < inverseTolerance) {
+            break
+        }
+    }
+    return [lat, lon]
+}
+
+// 不判断边界的 WGS84 -> GCJ02，反解迭代中不会因为越过边界而跳变
+function gcjOffset(lat: number, lon: number): [number, number] {
+    const [dLat, dLon] = gcjDelta(lat, lon)
+    return [lat + dLat, lon + dLon]
+}
+
+// GCJ02 相对 WGS84 的偏移量
+function gcjDelta(lat: number, lon: number): [number, number] {
+    let dLat = transformLat(lon - 105.0, lat - 35.0)
+    let dLon = transformLon(lon - 105.0, lat - 35.0)
+    const radLat = (lat / 180.0) * pi
+    let magic = Math.sin(radLat)
+    magic = 1 - ee * magic * magic
+    const sqrtMagic = Math.sqrt(magic)
+    dLat = (dLat * 180.0) / (((a * (1 - ee)) / (magic * sqrtMagic)) * pi)
+    dLon = (dLon * 180.0) / ((a / sqrtMagic) * Math.cos(radLat) * pi)
+    return [dLat, dLon]
+}
+
+// 是否在中国大陆边界外，边界外不做 GCJ02 偏移
 function outOfChina(lat: number, lon: number): boolean {
-    return lon < 72.004 || lon > 137.8347 || lat < 0.8293 || lat > 55.8271
+    return !inChina(lat, lon)
 }
 
 // 纬度转换
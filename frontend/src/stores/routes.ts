@@ -1,99 +1,105 @@
 import { defineStore } from 'pinia'
 import { ref } from 'vue'
+import { RouteService } from '../../bindings/iOSGhostRun/services'
+import type { RouteSummary } from '../../bindings/iOSGhostRun/services/models'
 
 export interface RoutePoint {
     lat: number
     lon: number
 }
 
-export interface SavedRoute {
-    name: string
-    points: RoutePoint[]
-    createdAt: number
-}
+export type SavedRoute = RouteSummary
+
+// 旧版保存在 localStorage 中的路线键
+const LEGACY_STORAGE_KEY = 'routes'
 
 export const useRoutesStore = defineStore(
     'routes',
     () => {
-        const routes = ref<SavedRoute[]>([])
+        // 上次编辑的路线只在前端自动保存，已保存路线由 RouteService 存放在磁盘
+        const lastRoute = ref<RoutePoint[] | null>(null)
 
         /**
          * 列出所有路线
          */
-        const listRoutes = (): SavedRoute[] => {
-            return routes.value
+        const listRoutes = async (): Promise<SavedRoute[]> => {
+            return await RouteService.ListRoutes()
         }
 
         /**
-         * 保存路线
+         * 保存路线，同名路线覆盖路线点
          */
-        const saveRoute = (name: string, points: RoutePoint[]): void => {
-            const existing = routes.value.findIndex(r => r.name === name)
-
-            const route: SavedRoute = {
-                name,
-                points,
-                createdAt: Date.now()
-            }
-
-            if (existing >= 0) {
-                routes.value[existing] = route
+        const saveRoute = async (name: string, points: RoutePoint[]): Promise<void> => {
+            const existing = (await RouteService.ListRoutes()).find(r => r.name === name)
+            if (existing) {
+                await RouteService.UpdateRoute(existing.id, points)
             } else {
-                routes.value.push(route)
+                await RouteService.CreateRoute(name, points, [])
             }
         }
 
         /**
          * 加载路线
          */
-        const loadRoute = (name: string): RoutePoint[] | null => {
-            const route = routes.value.find(r => r.name === name)
+        const loadRoute = async (id: string): Promise<RoutePoint[] | null> => {
+            const route = await RouteService.GetRoute(id)
             return route?.points ?? null
         }
 
         /**
          * 删除路线
          */
-        const deleteRoute = (name: string): void => {
-            routes.value = routes.value.filter(r => r.name !== name)
+        const deleteRoute = async (id: string): Promise<void> => {
+            await RouteService.DeleteRoute(id)
         }
 
         /**
          * 保存上次路线
          */
         const saveLastRoute = (points: RoutePoint[]): void => {
-            const route: SavedRoute = {
-                name: 'last_route',
-                points,
-                createdAt: Date.now()
-            }
-
-            const existing = routes.value.findIndex(r => r.name === route.name)
-            if (existing >= 0) {
-                routes.value[existing] = route
-            } else {
-                routes.value.push(route)
-            }
+            lastRoute.value = points
         }
 
         /**
          * 获取上次路线
          */
         const getLastRoute = (): RoutePoint[] | null => {
-            return loadRoute('last_route')
+            return lastRoute.value
+        }
+
+        /**
+         * 将旧版 localStorage 中的路线迁移到 RouteService，只在首次调用时生效
+         */
+        const migrateLegacyRoutes = async (): Promise<number> => {
+            const payload = localStorage.getItem(LEGACY_STORAGE_KEY) ?? ''
+            if (!lastRoute.value && payload) {
+                try {
+                    const legacy = JSON.parse(payload) as { routes?: { name: string; points: RoutePoint[] }[] }
+                    const last = legacy.routes?.find(r => r.name === 'last_route')
+                    if (last) lastRoute.value = last.points
+                } catch {
+                    // 旧数据损坏时交由 RouteService 报错
+                }
+            }
+            return await RouteService.MigrateLocalStorage(payload)
         }
 
         return {
-            routes,
+            lastRoute,
             listRoutes,
             saveRoute,
             loadRoute,
             deleteRoute,
             saveLastRoute,
-            getLastRoute
+            getLastRoute,
+            migrateLegacyRoutes
         }
     },
     {
-        persist: true
+        // 使用新键，避免覆盖尚未迁移的旧数据
+        persist: {
+            key: 'lastRoute',
+            pick: ['lastRoute']
+        }
     }
 )
@@ -7,6 +7,12 @@ import { defineConfig } from 'vite'
 // https://vitejs.dev/config/
 export default defineConfig({
     plugins: [vue(), wails('./bindings'), tailwindcss()],
+    server: {
+        fs: {
+            // 中国大陆边界数据与后端共用，位于 services/data
+            allow: ['..']
+        }
+    },
     resolve: {
         alias: {
             '@': path.resolve(__dirname, './src')
@@ -6,6 +6,7 @@ import (
 	"iOSGhostRun/services"
 	"log"
 	"log/slog"
+	"os"
 	"sync/atomic"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
@@ -26,10 +27,31 @@ func init() {
 	application.RegisterEvent[string]("developer-mode-menu-revealed")
 }
 
+// wailsEventSink 将服务事件转发到 Wails 前端
+type wailsEventSink struct {
+	app *application.App
+}
+
+func (s wailsEventSink) Emit(name string, data any) {
+	s.app.Event.Emit(name, data)
+}
+
 // main function serves as the application's entry point. It initializes the application, creates a window,
 // and starts a goroutine that emits a time-based event every second. It subsequently runs the application and
 // logs any error that might occur.
 func main() {
+	// 带子命令启动时进入无界面模式
+	if len(os.Args) > 1 {
+		switch name := os.Args[1]; name {
+		case "help", "-h", "--help":
+			printCLIUsage(os.Stdout)
+			return
+		default:
+			if _, ok := cliCommands[name]; ok {
+				os.Exit(runCLI(name, os.Args[2:]))
+			}
+		}
+	}
 
 	// Create a new Wails application by providing the necessary options.
 	// Variables 'Name' and 'Description' are for application metadata.
@@ -37,11 +59,30 @@ func main() {
 	// 'Bind' is a list of Go struct instances. The frontend has access to the methods of these instances.
 	// 'Mac' options tailor the application when running an macOS.
 
-	// 创建服务实例
-	loggerSvc := services.NewLoggerService()
-	devicesSvc := services.NewDevicesService()
-	locationSvc := services.NewLocationService()
-	runningSvc := services.NewRunningService(locationSvc)
+	// 创建服务实例，服务事件统一发布到事件总线，再由总线转发给前端
+	bus := services.NewEventBus()
+	loggerSvc := services.NewLoggerService(bus)
+
+	// 已有守护进程在运行时依附于它，复用其隧道和位置会话，不再自行启动隧道
+	var devicesSvc *services.DevicesService
+	var locationSvc *services.LocationService
+	daemon, err := services.DialDaemon(services.DefaultDaemonSocket())
+	if err == nil {
+		loggerSvc.Info("Main", "已连接到守护进程")
+		devicesSvc = services.NewDevicesServiceWithDaemon(bus, daemon)
+		locationSvc = services.NewLocationServiceWithBackend(daemon)
+		if _, err := daemon.Subscribe(bus.Emit, services.EventLog, services.EventDeveloperModeRevealed); err != nil {
+			loggerSvc.Warn("Main", "订阅守护进程事件失败: "+err.Error())
+		}
+	} else {
+		daemon = nil
+		devicesSvc = services.NewDevicesService(bus)
+		locationSvc = services.NewLocationService()
+	}
+	runningSvc := services.NewRunningService(locationSvc, bus)
+	routeFormatSvc := services.NewRouteFormatService()
+	coordinateSvc := services.NewCoordinateService()
+	routeSvc := services.NewRouteService()
 
 	app := application.New(application.Options{
 		Name:        "iOSGhostRun",
@@ -52,6 +93,9 @@ func main() {
 			application.NewService(devicesSvc),
 			application.NewService(locationSvc),
 			application.NewService(runningSvc),
+			application.NewService(routeFormatSvc),
+			application.NewService(coordinateSvc),
+			application.NewService(routeSvc),
 		},
 		Assets: application.AssetOptions{
 			Handler: application.AssetFileServerFS(assets),
@@ -63,6 +107,7 @@ func main() {
 	})
 
 	app.SetIcon(icon)
+	bus.Subscribe(wailsEventSink{app: app}.Emit)
 	var allowQuit atomic.Bool
 
 	// Create a new window with the necessary options.
@@ -99,15 +144,19 @@ func main() {
 		services.SetAppShuttingDown(true)
 		allowQuit.Store(true)
 		runningSvc.StopRun()
-		devInfo, err := devicesSvc.GetSelectedDevice()
-		if err == nil {
-			_ = services.UnmountImage(devInfo.UDID)
+		_ = locationSvc.Close()
+		// 依附守护进程时镜像由守护进程管理
+		if daemon == nil {
+			devInfo, err := devicesSvc.GetSelectedDevice()
+			if err == nil {
+				_ = services.UnmountImage(devInfo.UDID)
+			}
 		}
 		window.Close()
 	})
 
 	// Run the application. This blocks until the application has been exited.
-	err := app.Run()
+	err = app.Run()
 	// If an error occurred while running the application, log it and exit.
 	if err != nil {
 		log.Fatal(err)
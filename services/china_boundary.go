@@ -0,0 +1,138 @@
+package services
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// chinaBoundaryGeoJSON 中国大陆（含海南，不含香港、澳门、台湾及金门、马祖）的边界
+//
+// 取自 timezone-boundary-builder 2025b 的 Asia/Shanghai 时区（含领海），该时区边界沿用
+// OpenStreetMap 的行政区划边界，与香港、澳门、台湾的时区分开；按 20 米容差简化，坐标保留 5 位小数。
+// 数据 © OpenStreetMap contributors，以 ODbL 授权。
+//
+//go:embed data/china_boundary.geojson
+var chinaBoundaryGeoJSON []byte
+
+// boundaryBandHeight 边界索引的纬度分带高度（度）
+const boundaryBandHeight = 0.05
+
+var (
+	chinaBoundaryOnce  sync.Once
+	chinaBoundaryIndex *boundaryIndex
+)
+
+// inChina 点是否位于中国大陆边界内，即 GCJ02 偏移适用的范围
+func inChina(lat, lon float64) bool {
+	chinaBoundaryOnce.Do(func() {
+		idx, err := parseBoundary(chinaBoundaryGeoJSON)
+		if err != nil {
+			// 内置数据无法解析属于构建错误
+			panic(err)
+		}
+		chinaBoundaryIndex = idx
+	})
+	return chinaBoundaryIndex.contains(lat, lon)
+}
+
+// boundaryEdge 多边形的一条边
+type boundaryEdge struct {
+	lat1, lon1, lat2, lon2 float64
+}
+
+// boundaryIndex 按纬度分带的多边形边索引
+//
+// 每条边登记到它跨越的全部纬度带，判断点时只需对所在带内的边做射线法，
+// 所有环按奇偶规则合并，洞无需区分方向。
+type boundaryIndex struct {
+	minLat, maxLat, minLon, maxLon float64
+	bands                          [][]boundaryEdge
+}
+
+// parseBoundary 解析 Polygon 或 MultiPolygon（可包裹在 Feature 中）并建立索引
+func parseBoundary(data []byte) (*boundaryIndex, error) {
+	var root geoJSONObject
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("解析边界数据失败: %w", err)
+	}
+	if root.Type == "Feature" && root.Geometry != nil {
+		root = *root.Geometry
+	}
+
+	var polygons [][][][]float64
+	switch root.Type {
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(root.Coordinates, &rings); err != nil {
+			return nil, fmt.Errorf("解析边界坐标失败: %w", err)
+		}
+		polygons = [][][][]float64{rings}
+	case "MultiPolygon":
+		if err := json.Unmarshal(root.Coordinates, &polygons); err != nil {
+			return nil, fmt.Errorf("解析边界坐标失败: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("边界数据必须是 Polygon 或 MultiPolygon")
+	}
+
+	idx := &boundaryIndex{minLat: math.Inf(1), maxLat: math.Inf(-1), minLon: math.Inf(1), maxLon: math.Inf(-1)}
+	var edges []boundaryEdge
+	for _, rings := range polygons {
+		for _, ring := range rings {
+			for i := range ring {
+				if len(ring[i]) < 2 {
+					return nil, fmt.Errorf("无效的边界坐标: %v", ring[i])
+				}
+				a, b := ring[i], ring[(i+1)%len(ring)]
+				if len(b) < 2 {
+					return nil, fmt.Errorf("无效的边界坐标: %v", b)
+				}
+				idx.minLat = math.Min(idx.minLat, a[1])
+				idx.maxLat = math.Max(idx.maxLat, a[1])
+				idx.minLon = math.Min(idx.minLon, a[0])
+				idx.maxLon = math.Max(idx.maxLon, a[0])
+				if a[1] != b[1] {
+					edges = append(edges, boundaryEdge{lat1: a[1], lon1: a[0], lat2: b[1], lon2: b[0]})
+				}
+			}
+		}
+	}
+	if len(edges) == 0 {
+		return nil, fmt.Errorf("边界数据为空")
+	}
+
+	idx.bands = make([][]boundaryEdge, idx.band(idx.maxLat)+1)
+	for _, e := range edges {
+		lo, hi := idx.band(math.Min(e.lat1, e.lat2)), idx.band(math.Max(e.lat1, e.lat2))
+		for b := lo; b <= hi; b++ {
+			idx.bands[b] = append(idx.bands[b], e)
+		}
+	}
+	return idx, nil
+}
+
+func (idx *boundaryIndex) band(lat float64) int {
+	return int((lat - idx.minLat) / boundaryBandHeight)
+}
+
+// contains 向东发射水平射线，穿过奇数条边时点在多边形内
+func (idx *boundaryIndex) contains(lat, lon float64) bool {
+	if lat < idx.minLat || lat > idx.maxLat || lon < idx.minLon || lon > idx.maxLon {
+		return false
+	}
+
+	inside := false
+	for _, e := range idx.bands[idx.band(lat)] {
+		if (e.lat1 > lat) == (e.lat2 > lat) {
+			continue
+		}
+		crossLon := e.lon1 + (lat-e.lat1)*(e.lon2-e.lon1)/(e.lat2-e.lat1)
+		if lon < crossLon {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,83 @@
+package services
+
+import "testing"
+
+func TestInChinaBorderCities(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     bool
+	}{
+		// 深圳 / 香港
+		{"深圳福田", 22.5431, 114.0579, true},
+		{"深圳蛇口", 22.4800, 113.9100, true},
+		{"深圳罗湖", 22.5450, 114.1200, true},
+		{"深圳沙头角", 22.5500, 114.2320, true},
+		{"深圳盐田", 22.5700, 114.2700, true},
+		{"香港中环", 22.2819, 114.1588, false},
+		{"香港上水", 22.5016, 114.1281, false},
+		{"香港落马洲", 22.5150, 114.0700, false},
+		{"香港沙头角", 22.5400, 114.2220, false},
+		// 珠海 / 澳门
+		{"珠海香洲", 22.2711, 113.5767, true},
+		{"珠海拱北", 22.2215, 113.5500, true},
+		{"珠海横琴", 22.1300, 113.5300, true},
+		{"澳门半岛", 22.1987, 113.5439, false},
+		{"澳门氹仔", 22.1550, 113.5560, false},
+		// 丹东 / 新义州
+		{"丹东", 40.1246, 124.3830, true},
+		{"新义州", 40.0800, 124.4200, false},
+		// 集安 / 满浦
+		{"集安", 41.1200, 126.1800, true},
+		{"满浦", 41.1500, 126.2900, false},
+		// 磨憨 / 磨丁
+		{"磨憨", 21.1900, 101.7000, true},
+		{"磨丁", 21.1600, 101.6600, false},
+		// 东兴 / 芒街
+		{"东兴", 21.5450, 107.9700, true},
+		{"芒街", 21.5250, 107.9600, false},
+		// 厦门 / 金门
+		{"厦门", 24.4798, 118.0894, true},
+		{"大嶝岛", 24.5600, 118.3300, true},
+		{"金门", 24.4400, 118.3800, false},
+		{"小金门", 24.4300, 118.2400, false},
+		{"台北", 25.0330, 121.5654, false},
+	}
+	for _, tt := range tests {
+		if got := inChina(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("%s (%.4f, %.4f): inChina = %v, want %v", tt.name, tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+// 东兴市区范围内不应在边界内外来回跳变
+func TestInChinaDongxingStable(t *testing.T) {
+	for lat := 21.545; lat <= 21.560; lat += 0.001 {
+		for lon := 107.960; lon <= 107.990; lon += 0.001 {
+			if !inChina(lat, lon) {
+				t.Errorf("东兴 (%.3f, %.3f) 应在边界内", lat, lon)
+			}
+		}
+	}
+}
+
+func TestParseBoundaryHole(t *testing.T) {
+	idx, err := parseBoundary([]byte(`{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]],[[4,4],[6,4],[6,6],[4,6],[4,4]]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		lat, lon float64
+		want     bool
+	}{{1, 1, true}, {5, 5, false}, {9, 5, true}, {11, 5, false}, {-1, 5, false}} {
+		if got := idx.contains(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("contains(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkInChina(b *testing.B) {
+	for i := 0; b.Loop(); i++ {
+		inChina(22.5+float64(i%100)*0.001, 114.0+float64(i%97)*0.001)
+	}
+}
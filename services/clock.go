@@ -0,0 +1,141 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时间来源，跑步服务通过它读取时间和创建定时器，替换后可回放和测试
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker 周期定时器
+type Ticker interface {
+	C() <-chan time.Time
+	// Done 通知本次触发已处理完毕，接收方每处理完一次触发调用一次
+	Done()
+	Stop()
+}
+
+// realClock 系统时钟
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+func (realTicker) Done() {}
+
+// ManualClock 手动推进的时钟，Advance 时依次触发到期的定时器
+//
+// 每次触发都会等到接收方取走并调用 Done 后才继续，Advance 返回时到期的每一步都已处理完毕，
+// 因此跑步循环按确定的顺序逐步执行，返回后修改时钟或服务状态不会影响已触发的步。
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*manualTicker
+}
+
+// NewManualClock 创建从 start 开始的手动时钟
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("ManualClock: 定时器间隔必须大于 0")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &manualTicker{
+		c:        make(chan time.Time),
+		ack:      make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		interval: d,
+		next:     c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance 将时钟推进 d，期间到期的定时器按时间顺序逐个触发，每次触发处理完毕后才触发下一次
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		var due *manualTicker
+		for _, t := range c.tickers {
+			if !t.stopped() && !t.next.After(end) && (due == nil || t.next.Before(due.next)) {
+				due = t
+			}
+		}
+		if due == nil {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+		c.now = due.next
+		due.next = due.next.Add(due.interval)
+		now := c.now
+		c.mu.Unlock()
+
+		select {
+		case due.c <- now:
+		case <-due.done:
+			continue
+		}
+		// 等待接收方处理完这一步，定时器停止时不再等待
+		select {
+		case <-due.ack:
+		case <-due.done:
+		}
+	}
+}
+
+type manualTicker struct {
+	c        chan time.Time
+	ack      chan struct{} // 接收方处理完一次触发
+	done     chan struct{}
+	once     sync.Once
+	interval time.Duration
+	next     time.Time
+}
+
+func (t *manualTicker) C() <-chan time.Time { return t.c }
+
+func (t *manualTicker) Done() {
+	select {
+	case t.ack <- struct{}{}:
+	default:
+	}
+}
+
+func (t *manualTicker) Stop() {
+	t.once.Do(func() { close(t.done) })
+}
+
+func (t *manualTicker) stopped() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// CoordSystem 坐标系
+type CoordSystem string
+
+const (
+	CoordWGS84 CoordSystem = "WGS84" // GPS/iOS
+	CoordGCJ02 CoordSystem = "GCJ02" // 国测局，高德、腾讯
+	CoordBD09  CoordSystem = "BD09"  // 百度
+)
+
+// 坐标系转换常量，与 frontend/src/lib/transform.ts 一致
+const (
+	coordXPi = math.Pi * 3000.0 / 180.0
+	coordA   = 6378245.0              // 长半轴
+	coordEE  = 0.00669342162296594323 // 扁率
+)
+
+// ParseCoordSystem 解析坐标系名称，不区分大小写和连字符，空串视为 WGS84
+func ParseCoordSystem(s string) (CoordSystem, error) {
+	switch strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(s)), "-", "") {
+	case "", "WGS84":
+		return CoordWGS84, nil
+	case "GCJ02":
+		return CoordGCJ02, nil
+	case "BD09", "BD09LL":
+		return CoordBD09, nil
+	default:
+		return "", fmt.Errorf("不支持的坐标系: %s", s)
+	}
+}
+
+const (
+	// coordInverseTolerance 迭代反解的收敛阈值（度），约 0.1 mm
+	coordInverseTolerance = 1e-9
+	// coordInverseMaxIterations 迭代反解的次数上限
+	coordInverseMaxIterations = 20
+)
+
+// WGS84ToGCJ02 WGS84 -> GCJ02
+func WGS84ToGCJ02(lat, lon float64) (float64, float64) {
+	if outOfChina(lat, lon) {
+		return lat, lon
+	}
+	return gcjOffset(lat, lon)
+}
+
+// GCJ02ToWGS84 GCJ02 -> WGS84
+//
+// 以一步近似为初值迭代反解 WGS84ToGCJ02，结果再正向转换后与输入的误差小于 coordInverseTolerance。
+// 是否偏移按反解得到的 WGS84 点判断：反解落在边界外时正向转换不偏移，原样返回输入；
+// 边界内侧紧贴边界的少数点不是任何 WGS84 点的正向结果，同样原样返回。
+func GCJ02ToWGS84(lat, lon float64) (float64, float64) {
+	dLat, dLon := gcjDelta(lat, lon)
+	wgsLat, wgsLon := invertCoordinate(lat, lon, lat-dLat, lon-dLon, gcjOffset)
+	if outOfChina(wgsLat, wgsLon) {
+		return lat, lon
+	}
+	return wgsLat, wgsLon
+}
+
+// BD09ToGCJ02 BD09 -> GCJ02，迭代反解 GCJ02ToBD09
+func BD09ToGCJ02(lat, lon float64) (float64, float64) {
+	x := lon - 0.0065
+	y := lat - 0.006
+	z := math.Sqrt(x*x+y*y) - 0.00002*math.Sin(y*coordXPi)
+	theta := math.Atan2(y, x) - 0.000003*math.Cos(x*coordXPi)
+	return invertCoordinate(lat, lon, z*math.Sin(theta), z*math.Cos(theta), GCJ02ToBD09)
+}
+
+// GCJ02ToBD09 GCJ02 -> BD09
+func GCJ02ToBD09(lat, lon float64) (float64, float64) {
+	z := math.Sqrt(lon*lon+lat*lat) + 0.00002*math.Sin(lat*coordXPi)
+	theta := math.Atan2(lat, lon) + 0.000003*math.Cos(lon*coordXPi)
+	return z*math.Sin(theta) + 0.006, z*math.Cos(theta) + 0.0065
+}
+
+// TransformCoordinate 在任意两个坐标系间转换
+func TransformCoordinate(lat, lon float64, from, to CoordSystem) (float64, float64) {
+	if from == to {
+		return lat, lon
+	}
+
+	gcjLat, gcjLon := lat, lon
+	switch from {
+	case CoordWGS84:
+		gcjLat, gcjLon = WGS84ToGCJ02(lat, lon)
+	case CoordBD09:
+		gcjLat, gcjLon = BD09ToGCJ02(lat, lon)
+	}
+
+	switch to {
+	case CoordWGS84:
+		return GCJ02ToWGS84(gcjLat, gcjLon)
+	case CoordBD09:
+		return GCJ02ToBD09(gcjLat, gcjLon)
+	default:
+		return gcjLat, gcjLon
+	}
+}
+
+// TransformPoints 批量转换坐标，返回新切片，其余字段保持不变
+func TransformPoints(points []Point, from, to CoordSystem) []Point {
+	out := make([]Point, len(points))
+	for i, p := range points {
+		p.Lat, p.Lon = TransformCoordinate(p.Lat, p.Lon, from, to)
+		out[i] = p
+	}
+	return out
+}
+
+// invertCoordinate 从初值 (lat, lon) 出发，用不动点迭代求 forward 在 (targetLat, targetLon) 处的反解
+//
+// 偏移量随位置变化很慢，每次迭代误差缩小几个数量级，通常 2~3 次即可收敛。
+func invertCoordinate(targetLat, targetLon, lat, lon float64, forward func(lat, lon float64) (float64, float64)) (float64, float64) {
+	for range coordInverseMaxIterations {
+		fLat, fLon := forward(lat, lon)
+		dLat, dLon := targetLat-fLat, targetLon-fLon
+		lat += dLat
+		lon += dLon
+		if math.Abs(dLat) < coordInverseTolerance && math.Abs(dLon) < coordInverseTolerance {
+			break
+		}
+	}
+	return lat, lon
+}
+
+// gcjOffset 不判断边界的 WGS84 -> GCJ02，反解迭代中不会因为越过边界而跳变
+func gcjOffset(lat, lon float64) (float64, float64) {
+	dLat, dLon := gcjDelta(lat, lon)
+	return lat + dLat, lon + dLon
+}
+
+// gcjDelta GCJ02 相对 WGS84 的偏移量
+func gcjDelta(lat, lon float64) (float64, float64) {
+	dLat := transformLat(lon-105.0, lat-35.0)
+	dLon := transformLon(lon-105.0, lat-35.0)
+	radLat := lat / 180.0 * math.Pi
+	magic := math.Sin(radLat)
+	magic = 1 - coordEE*magic*magic
+	sqrtMagic := math.Sqrt(magic)
+	dLat = (dLat * 180.0) / ((coordA * (1 - coordEE)) / (magic * sqrtMagic) * math.Pi)
+	dLon = (dLon * 180.0) / (coordA / sqrtMagic * math.Cos(radLat) * math.Pi)
+	return dLat, dLon
+}
+
+// outOfChina 是否在中国大陆边界外，边界外不做 GCJ02 偏移
+func outOfChina(lat, lon float64) bool {
+	return !inChina(lat, lon)
+}
+
+// transformLat 纬度转换
+func transformLat(x, y float64) float64 {
+	ret := -100.0 + 2.0*x + 3.0*y + 0.2*y*y + 0.1*x*y + 0.2*math.Sqrt(math.Abs(x))
+	ret += (20.0*math.Sin(6.0*x*math.Pi) + 20.0*math.Sin(2.0*x*math.Pi)) * 2.0 / 3.0
+	ret += (20.0*math.Sin(y*math.Pi) + 40.0*math.Sin(y/3.0*math.Pi)) * 2.0 / 3.0
+	ret += (160.0*math.Sin(y/12.0*math.Pi) + 320*math.Sin(y*math.Pi/30.0)) * 2.0 / 3.0
+	return ret
+}
+
+// transformLon 经度转换
+func transformLon(x, y float64) float64 {
+	ret := 300.0 + x + 2.0*y + 0.1*x*x + 0.1*x*y + 0.1*math.Sqrt(math.Abs(x))
+	ret += (20.0*math.Sin(6.0*x*math.Pi) + 20.0*math.Sin(2.0*x*math.Pi)) * 2.0 / 3.0
+	ret += (20.0*math.Sin(x*math.Pi) + 40.0*math.Sin(x/3.0*math.Pi)) * 2.0 / 3.0
+	ret += (150.0*math.Sin(x/12.0*math.Pi) + 300.0*math.Sin(x/30.0*math.Pi)) * 2.0 / 3.0
+	return ret
+}
@@ -0,0 +1,49 @@
+package services
+
+// CoordinateService 坐标系转换服务，WGS84、GCJ02、BD09 之间互转
+type CoordinateService struct{}
+
+func NewCoordinateService() *CoordinateService {
+	return &CoordinateService{}
+}
+
+// Transform 转换单个坐标
+func (s *CoordinateService) Transform(lat, lon float64, from, to CoordSystem) (Point, error) {
+	fromCS, toCS, err := parseCoordSystems(from, to)
+	if err != nil {
+		return Point{}, err
+	}
+	lat, lon = TransformCoordinate(lat, lon, fromCS, toCS)
+	return Point{Lat: lat, Lon: lon}, nil
+}
+
+// TransformPoints 批量转换路线点，其余字段保持不变
+func (s *CoordinateService) TransformPoints(points []Point, from, to CoordSystem) ([]Point, error) {
+	fromCS, toCS, err := parseCoordSystems(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return TransformPoints(points, fromCS, toCS), nil
+}
+
+// ToWGS84 将路线点从指定坐标系转换为 WGS84
+func (s *CoordinateService) ToWGS84(points []Point, from CoordSystem) ([]Point, error) {
+	return s.TransformPoints(points, from, CoordWGS84)
+}
+
+// FromWGS84 将 WGS84 路线点转换为指定坐标系
+func (s *CoordinateService) FromWGS84(points []Point, to CoordSystem) ([]Point, error) {
+	return s.TransformPoints(points, CoordWGS84, to)
+}
+
+func parseCoordSystems(from, to CoordSystem) (CoordSystem, CoordSystem, error) {
+	fromCS, err := ParseCoordSystem(string(from))
+	if err != nil {
+		return "", "", err
+	}
+	toCS, err := ParseCoordSystem(string(to))
+	if err != nil {
+		return "", "", err
+	}
+	return fromCS, toCS, nil
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+// mainlandGrid 大陆边界内每隔 1° 取一点
+func mainlandGrid() [][2]float64 {
+	var points [][2]float64
+	for lat := 18.5; lat <= 53.5; lat++ {
+		for lon := 73.5; lon <= 134.5; lon++ {
+			if inChina(lat, lon) {
+				points = append(points, [2]float64{lat, lon})
+			}
+		}
+	}
+	return points
+}
+
+// borderPoints 靠近香港、澳门、金门和陆地边境的大陆一侧的点
+var borderPoints = [][2]float64{
+	{22.5431, 114.0579}, // 深圳福田
+	{22.5450, 114.1200}, // 深圳罗湖
+	{22.5500, 114.2320}, // 深圳沙头角
+	{22.2215, 113.5500}, // 珠海拱北
+	{22.1300, 113.5300}, // 珠海横琴
+	{24.5600, 118.3300}, // 大嶝岛
+	{40.1246, 124.3830}, // 丹东
+	{21.1900, 101.7000}, // 磨憨
+	{21.5450, 107.9700}, // 东兴
+}
+
+func TestCoordinateRoundTrip(t *testing.T) {
+	points := append(mainlandGrid(), borderPoints...)
+	if len(points) < 500 {
+		t.Fatalf("大陆网格只有 %d 个点", len(points))
+	}
+
+	cases := []struct {
+		name string
+		to   CoordSystem
+	}{
+		{"WGS84→GCJ02→WGS84", CoordGCJ02},
+		{"WGS84→BD09→WGS84", CoordBD09},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, p := range points {
+				lat, lon := TransformCoordinate(p[0], p[1], CoordWGS84, c.to)
+				if lat == p[0] && lon == p[1] {
+					t.Fatalf("(%.4f, %.4f) 未做偏移", p[0], p[1])
+				}
+				lat, lon = TransformCoordinate(lat, lon, c.to, CoordWGS84)
+				if math.Abs(lat-p[0]) > coordInverseTolerance || math.Abs(lon-p[1]) > coordInverseTolerance {
+					t.Errorf("(%.4f, %.4f) 往返后误差 (%.2e, %.2e)", p[0], p[1], lat-p[0], lon-p[1])
+				}
+			}
+		})
+	}
+}
+
+func TestCoordinateOutsideChinaUnchanged(t *testing.T) {
+	for _, p := range [][2]float64{
+		{22.2819, 114.1588}, // 香港中环
+		{22.1987, 113.5439}, // 澳门半岛
+		{24.4400, 118.3800}, // 金门
+		{25.0330, 121.5654}, // 台北
+		{37.7749, -122.4194},
+	} {
+		for _, cs := range []CoordSystem{CoordGCJ02, CoordWGS84} {
+			from := CoordWGS84
+			if cs == CoordWGS84 {
+				from = CoordGCJ02
+			}
+			if lat, lon := TransformCoordinate(p[0], p[1], from, cs); lat != p[0] || lon != p[1] {
+				t.Errorf("(%.4f, %.4f) %s→%s = (%.6f, %.6f)，边界外应保持不变", p[0], p[1], from, cs, lat, lon)
+			}
+		}
+	}
+}
+
+// 反解落在边界内时再正向转换的误差小于阈值，否则原样返回输入
+func TestGCJ02ToWGS84NearBorder(t *testing.T) {
+	shifted := 0
+	for _, p := range borderPoints {
+		for dLat := -0.02; dLat <= 0.02; dLat += 0.002 {
+			for dLon := -0.02; dLon <= 0.02; dLon += 0.002 {
+				gcjLat, gcjLon := p[0]+dLat, p[1]+dLon
+				lat, lon := GCJ02ToWGS84(gcjLat, gcjLon)
+				if lat == gcjLat && lon == gcjLon {
+					// 迭代得到的 WGS84 点在边界外，正向转换不偏移；边界内侧紧贴边界的少数点没有反解，同样原样返回
+					if wLat, wLon := invertCoordinate(gcjLat, gcjLon, gcjLat, gcjLon, gcjOffset); inChina(wLat, wLon) {
+						t.Errorf("(%.4f, %.4f) 的反解 (%.6f, %.6f) 在边界内却未做偏移", gcjLat, gcjLon, wLat, wLon)
+					}
+					continue
+				}
+				shifted++
+				if !inChina(lat, lon) {
+					t.Errorf("(%.4f, %.4f) 的反解 (%.6f, %.6f) 在边界外", gcjLat, gcjLon, lat, lon)
+				}
+				fLat, fLon := WGS84ToGCJ02(lat, lon)
+				if math.Abs(fLat-gcjLat) > coordInverseTolerance || math.Abs(fLon-gcjLon) > coordInverseTolerance {
+					t.Errorf("(%.4f, %.4f) 反解后正向误差 (%.2e, %.2e)", gcjLat, gcjLon, fLat-gcjLat, fLon-gcjLon)
+				}
+			}
+		}
+	}
+	if shifted == 0 {
+		t.Error("边界附近没有做偏移的点")
+	}
+}
+
+func TestInvertCoordinateConverges(t *testing.T) {
+	cases := []struct {
+		name    string
+		forward func(lat, lon float64) (float64, float64)
+		initial func(lat, lon float64) (float64, float64)
+	}{
+		{"GCJ02", gcjOffset, func(lat, lon float64) (float64, float64) {
+			dLat, dLon := gcjDelta(lat, lon)
+			return lat - dLat, lon - dLon
+		}},
+		{"BD09", GCJ02ToBD09, func(lat, lon float64) (float64, float64) { return lat - 0.006, lon - 0.0065 }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			worst := 0
+			for _, p := range append(mainlandGrid(), borderPoints...) {
+				targetLat, targetLon := c.forward(p[0], p[1])
+				calls := 0
+				counted := func(lat, lon float64) (float64, float64) {
+					calls++
+					return c.forward(lat, lon)
+				}
+				initLat, initLon := c.initial(targetLat, targetLon)
+				lat, lon := invertCoordinate(targetLat, targetLon, initLat, initLon, counted)
+				if calls >= coordInverseMaxIterations {
+					t.Fatalf("(%.4f, %.4f) 迭代 %d 次仍未收敛", p[0], p[1], calls)
+				}
+				if fLat, fLon := c.forward(lat, lon); math.Abs(fLat-targetLat) > coordInverseTolerance || math.Abs(fLon-targetLon) > coordInverseTolerance {
+					t.Errorf("(%.4f, %.4f) 反解误差 (%.2e, %.2e)", p[0], p[1], fLat-targetLat, fLon-targetLon)
+				}
+				worst = max(worst, calls)
+			}
+			// 偏移量随位置变化很慢，几次迭代即可收敛
+			if worst > 5 {
+				t.Errorf("最多迭代 %d 次，期望不超过 5 次", worst)
+			}
+		})
+	}
+}
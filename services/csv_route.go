@@ -0,0 +1,309 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVColumns CSV 列映射
+//
+// 每项可以是表头名称（不区分大小写），也可以是从 1 开始的列号；
+// 为空时按常见表头名称自动识别。Lat、Lon 必须能够确定，其余可选。
+type CSVColumns struct {
+	Lat   string `json:"lat"`
+	Lon   string `json:"lon"`
+	Time  string `json:"time"`
+	Speed string `json:"speed"` // km/h
+	Ele   string `json:"ele"`   // m
+	Label string `json:"label"`
+}
+
+// CSVOptions CSV 导入选项
+type CSVOptions struct {
+	Delimiter   string      `json:"delimiter"`   // 分隔符，为空时自动检测
+	Header      string      `json:"header"`      // 是否有表头："yes"、"no"，为空时自动检测
+	Columns     CSVColumns  `json:"columns"`     // 列映射
+	CoordSystem CoordSystem `json:"coordSystem"` // 坐标系，为空时为 WGS84
+}
+
+// 自动识别列时使用的表头名称
+var csvColumnAliases = map[string][]string{
+	"lat":   {"lat", "latitude", "纬度", "y"},
+	"lon":   {"lon", "lng", "long", "longitude", "经度", "x"},
+	"time":  {"time", "timestamp", "datetime", "date", "时间"},
+	"speed": {"speed", "速度"},
+	"ele":   {"ele", "elevation", "alt", "altitude", "海拔", "高程"},
+	"label": {"label", "name", "名称", "备注"},
+}
+
+// ParseCSV 解析 CSV 路线，返回转换为 WGS84 的路线点
+func ParseCSV(r io.Reader, opts CSVOptions) ([]Point, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("读取 CSV 失败: %w", err)
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	cs, err := ParseCoordSystem(string(opts.CoordSystem))
+	if err != nil {
+		return nil, err
+	}
+
+	delim, err := csvDelimiter(data, opts.Delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.Comma = delim
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析 CSV 失败: %w", err)
+	}
+	records = csvNonEmpty(records)
+	if len(records) == 0 {
+		return nil, fmt.Errorf("CSV 中没有数据")
+	}
+
+	var header []string
+	switch strings.ToLower(opts.Header) {
+	case "yes", "true":
+		header = records[0]
+	case "no", "false":
+	case "":
+		if csvLooksLikeHeader(records) {
+			header = records[0]
+		}
+	default:
+		return nil, fmt.Errorf("无效的表头选项: %s", opts.Header)
+	}
+	rows := records
+	if header != nil {
+		rows = records[1:]
+	}
+
+	cols, err := resolveCSVColumns(opts.Columns, header, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0, len(rows))
+	for i, row := range rows {
+		line := i + 1
+		if header != nil {
+			line++
+		}
+		p, err := cols.point(row)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		points = append(points, p)
+	}
+	return TransformPoints(points, cs, CoordWGS84), nil
+}
+
+// csvDelimiter 返回分隔符，未指定时在逗号、分号、制表符和竖线中选择各行数量一致且最多的一个
+func csvDelimiter(data []byte, configured string) (rune, error) {
+	switch configured {
+	case "":
+	case `\t`, "tab":
+		return '\t', nil
+	default:
+		r := []rune(configured)
+		if len(r) != 1 {
+			return 0, fmt.Errorf("分隔符必须是单个字符")
+		}
+		return r[0], nil
+	}
+
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() && len(lines) < 20 {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	best, bestCount := ',', 0
+	for _, d := range []rune{',', ';', '\t', '|'} {
+		count := -1
+		for _, line := range lines {
+			n := strings.Count(line, string(d))
+			if count == -1 {
+				count = n
+			} else if n != count {
+				count = 0
+				break
+			}
+		}
+		if count > bestCount {
+			best, bestCount = d, count
+		}
+	}
+	return best, nil
+}
+
+func csvNonEmpty(records [][]string) [][]string {
+	out := records[:0]
+	for _, rec := range records {
+		for _, field := range rec {
+			if strings.TrimSpace(field) != "" {
+				out = append(out, rec)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// csvLooksLikeHeader 首行没有数字而第二行有数字时，认为首行是表头
+func csvLooksLikeHeader(records [][]string) bool {
+	numeric := func(row []string) int {
+		n := 0
+		for _, field := range row {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(field), 64); err == nil {
+				n++
+			}
+		}
+		return n
+	}
+	if len(records) < 2 {
+		return numeric(records[0]) == 0
+	}
+	return numeric(records[0]) < numeric(records[1])
+}
+
+// csvColumnIndex 已确定的列下标，-1 表示不存在
+type csvColumnIndex struct {
+	lat, lon, time, speed, ele, label int
+}
+
+func resolveCSVColumns(cols CSVColumns, header []string, rows [][]string) (csvColumnIndex, error) {
+	find := func(ref, key string) (int, error) {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			for i, h := range header {
+				if strings.EqualFold(strings.TrimSpace(h), ref) {
+					return i, nil
+				}
+			}
+			if n, err := strconv.Atoi(ref); err == nil && n >= 1 {
+				return n - 1, nil
+			}
+			return -1, fmt.Errorf("找不到列: %s", ref)
+		}
+		for _, alias := range csvColumnAliases[key] {
+			for i, h := range header {
+				if strings.EqualFold(strings.TrimSpace(h), alias) {
+					return i, nil
+				}
+			}
+		}
+		return -1, nil
+	}
+
+	var idx csvColumnIndex
+	var err error
+	for _, c := range []struct {
+		ref, key string
+		dst      *int
+	}{
+		{cols.Lat, "lat", &idx.lat},
+		{cols.Lon, "lon", &idx.lon},
+		{cols.Time, "time", &idx.time},
+		{cols.Speed, "speed", &idx.speed},
+		{cols.Ele, "ele", &idx.ele},
+		{cols.Label, "label", &idx.label},
+	} {
+		if *c.dst, err = find(c.ref, c.key); err != nil {
+			return idx, err
+		}
+	}
+
+	// 无表头且未指定经纬度列时，取前两列，首列超出纬度范围时按"经度,纬度"处理
+	if idx.lat < 0 && idx.lon < 0 && header == nil && len(rows) > 0 && len(rows[0]) >= 2 {
+		idx.lat, idx.lon = 0, 1
+		if v, err := strconv.ParseFloat(strings.TrimSpace(rows[0][0]), 64); err == nil && math.Abs(v) > 90 {
+			idx.lat, idx.lon = 1, 0
+		}
+	}
+	if idx.lat < 0 || idx.lon < 0 {
+		return idx, fmt.Errorf("无法确定经纬度列，请指定列映射")
+	}
+	return idx, nil
+}
+
+func (c csvColumnIndex) point(row []string) (Point, error) {
+	field := func(i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	lat, err := strconv.ParseFloat(field(c.lat), 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("无效的纬度: %q", field(c.lat))
+	}
+	lon, err := strconv.ParseFloat(field(c.lon), 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("无效的经度: %q", field(c.lon))
+	}
+	p := Point{Lat: lat, Lon: lon, Label: field(c.label)}
+
+	if s := field(c.ele); s != "" {
+		if p.Ele, err = strconv.ParseFloat(s, 64); err != nil {
+			return Point{}, fmt.Errorf("无效的海拔: %q", s)
+		}
+	}
+	if s := field(c.speed); s != "" {
+		if p.Speed, err = strconv.ParseFloat(s, 64); err != nil {
+			return Point{}, fmt.Errorf("无效的速度: %q", s)
+		}
+	}
+	if s := field(c.time); s != "" {
+		t, ok := parseCSVTime(s)
+		if !ok {
+			return Point{}, fmt.Errorf("无效的时间: %q", s)
+		}
+		p.Time = t
+	}
+	return p, nil
+}
+
+// parseCSVTime 支持 RFC3339、常见日期时间格式（按本地时区）以及秒或毫秒级 Unix 时间戳
+func parseCSVTime(s string) (time.Time, bool) {
+	if t, ok := parseGPXTime(s); ok && strings.ContainsAny(s, "Zz+") {
+		return t, true
+	}
+	for _, layout := range []string{
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999",
+		"2006/01/02 15:04:05",
+		"2006/1/2 15:04:05",
+		"2006-01-02 15:04",
+		"2006/1/2 15:04",
+	} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	if t, ok := parseGPXTime(s); ok {
+		return t, true
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n > 1e11 {
+			return time.UnixMilli(n), true
+		}
+		return time.Unix(n, 0), true
+	}
+	return time.Time{}, false
+}
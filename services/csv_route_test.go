@@ -0,0 +1,144 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSV(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		opts CSVOptions
+		want []Point
+	}{
+		{
+			name: "逗号分隔带表头",
+			data: "latitude,longitude,ele,speed,name\n30.1,120.1,12.5,10,起点\n30.2,120.2,,,\n",
+			want: []Point{{Lat: 30.1, Lon: 120.1, Ele: 12.5, Speed: 10, Label: "起点"}, {Lat: 30.2, Lon: 120.2}},
+		},
+		{
+			name: "分号分隔中文表头",
+			data: "\xef\xbb\xbf经度;纬度;备注\n120.1;30.1;补给\n\n120.2;30.2;\n",
+			want: []Point{{Lat: 30.1, Lon: 120.1, Label: "补给"}, {Lat: 30.2, Lon: 120.2}},
+		},
+		{
+			name: "制表符分隔",
+			data: "lng\tlat\n120.1\t30.1\n120.2\t30.2\n",
+			want: []Point{{Lat: 30.1, Lon: 120.1}, {Lat: 30.2, Lon: 120.2}},
+		},
+		{
+			name: "竖线分隔且字段中有逗号",
+			data: "lat|lon|label\n30.1|120.1|桥下, 左转\n30.2|120.2|终点\n",
+			want: []Point{{Lat: 30.1, Lon: 120.1, Label: "桥下, 左转"}, {Lat: 30.2, Lon: 120.2, Label: "终点"}},
+		},
+		{
+			name: "无表头纬度在前",
+			data: "30.1,120.1\n30.2,120.2\n",
+			want: []Point{{Lat: 30.1, Lon: 120.1}, {Lat: 30.2, Lon: 120.2}},
+		},
+		{
+			name: "无表头经度在前",
+			data: "120.1, 30.1\n120.2, 30.2\n",
+			want: []Point{{Lat: 30.1, Lon: 120.1}, {Lat: 30.2, Lon: 120.2}},
+		},
+		{
+			name: "指定无表头和列号",
+			data: "a,30.1,120.1\nb,30.2,120.2\n",
+			opts: CSVOptions{Header: "no", Columns: CSVColumns{Lat: "2", Lon: "3", Label: "1"}},
+			want: []Point{{Lat: 30.1, Lon: 120.1, Label: "a"}, {Lat: 30.2, Lon: 120.2, Label: "b"}},
+		},
+		{
+			name: "指定分隔符和列名",
+			data: "N:E\n30.1:120.1\n30.2:120.2\n",
+			opts: CSVOptions{Delimiter: ":", Header: "yes", Columns: CSVColumns{Lat: "n", Lon: "e"}},
+			want: []Point{{Lat: 30.1, Lon: 120.1}, {Lat: 30.2, Lon: 120.2}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			points, err := ParseCSV(strings.NewReader(c.data), c.opts)
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if len(points) != len(c.want) {
+				t.Fatalf("解析出 %d 个点，期望 %d 个: %+v", len(points), len(c.want), points)
+			}
+			for i, p := range points {
+				if p != c.want[i] {
+					t.Errorf("第 %d 个点 = %+v，期望 %+v", i, p, c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseCSVCoordSystem(t *testing.T) {
+	const data = "lat,lon\n39.908823,116.39747\n31.230416,121.473701\n"
+	gcj, err := ParseCSV(strings.NewReader(data), CSVOptions{CoordSystem: CoordGCJ02})
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	wgs, err := ParseCSV(strings.NewReader(data), CSVOptions{})
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	for i := range wgs {
+		lat, lon := GCJ02ToWGS84(wgs[i].Lat, wgs[i].Lon)
+		if gcj[i].Lat != lat || gcj[i].Lon != lon {
+			t.Errorf("第 %d 个点 = (%.6f, %.6f)，期望转换为 WGS84 后的 (%.6f, %.6f)", i, gcj[i].Lat, gcj[i].Lon, lat, lon)
+		}
+		if gcj[i] == wgs[i] {
+			t.Errorf("第 %d 个点 GCJ02 坐标未做转换", i)
+		}
+	}
+	if _, err := ParseCSV(strings.NewReader(data), CSVOptions{CoordSystem: "UTM"}); err == nil {
+		t.Error("未知坐标系应返回错误")
+	}
+}
+
+func TestParseCSVTime(t *testing.T) {
+	want := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	for _, s := range []string{"2024-05-01T07:00:00Z", "2024-05-01T15:00:00+08:00", "1714546800", "1714546800000"} {
+		got, ok := parseCSVTime(s)
+		if !ok || !got.Equal(want) {
+			t.Errorf("%q 解析为 %v, %v，期望 %v", s, got, ok, want)
+		}
+	}
+	local := time.Date(2024, 5, 1, 7, 0, 0, 0, time.Local)
+	for _, s := range []string{"2024-05-01 07:00:00", "2024/5/1 07:00:00", "2024-05-01 07:00"} {
+		got, ok := parseCSVTime(s)
+		if !ok || !got.Equal(local) {
+			t.Errorf("%q 解析为 %v, %v，期望本地时间 %v", s, got, ok, local)
+		}
+	}
+	if _, ok := parseCSVTime("昨天早上"); ok {
+		t.Error("无效时间不应解析成功")
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		opts CSVOptions
+		err  string
+	}{
+		{"空文件", "\n\n", CSVOptions{}, "没有数据"},
+		{"无法确定经纬度列", "name,value\na,1\n", CSVOptions{}, "无法确定经纬度列"},
+		{"找不到指定列", "lat,lon\n30,120\n", CSVOptions{Columns: CSVColumns{Lat: "纬度"}}, "找不到列"},
+		{"无效纬度", "lat,lon\n30,120\nabc,120\n", CSVOptions{}, "第 3 行: 无效的纬度"},
+		{"无效时间", "lat,lon,time\n30,120,昨天\n", CSVOptions{}, "无效的时间"},
+		{"多字符分隔符", "lat,lon\n30,120\n", CSVOptions{Delimiter: "::"}, "单个字符"},
+		{"无效表头选项", "lat,lon\n30,120\n", CSVOptions{Header: "maybe"}, "无效的表头选项"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseCSV(strings.NewReader(c.data), c.opts)
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("错误 = %v，期望包含 %q", err, c.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,575 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 守护进程通过本地套接字对外提供服务，客户端与之逐行交换 JSON 消息：
+//
+//	请求 {"id":1,"method":"SelectDevice","params":{"udid":"..."}}
+//	响应 {"id":1,"result":...} 或 {"id":1,"error":"..."}
+//	事件 {"event":"log-event","data":...}，仅发送给调用过 Subscribe 的连接
+//
+// 守护进程只持有隧道和位置模拟会话，跑步由客户端进程自己的 RunningService 驱动，逐点通过 SetLocation 下发。
+// Windows 10 1803 起同样支持 Unix 套接字，因此各平台使用同一种传输方式。
+
+// DefaultDaemonSocket 返回守护进程默认的套接字路径，位于当前用户私有的运行目录：
+// 优先 $XDG_RUNTIME_DIR，其次用户缓存目录，都不可用时退回系统临时目录下按用户区分的子目录
+func DefaultDaemonSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		if cache, err := os.UserCacheDir(); err == nil {
+			dir = cache
+		} else {
+			dir = filepath.Join(os.TempDir(), fmt.Sprintf("iosghostrun-%d", os.Getuid()))
+		}
+	}
+	return filepath.Join(dir, "iosghostrun", "daemon.sock")
+}
+
+// prepareSocketDir 创建只有当前用户可访问的套接字目录，监听前即限制权限，
+// 避免套接字在 Chmod 之前被其他用户连接
+func prepareSocketDir(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("创建套接字目录失败: %w", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("检查套接字目录失败: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("套接字目录不是目录: %s", dir)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		if err := os.Chmod(dir, 0o700); err != nil {
+			return fmt.Errorf("限制套接字目录权限失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// daemonMessage 守护进程协议消息
+type daemonMessage struct {
+	ID     int64           `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Event  string          `json:"event,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+type daemonHandler func(params json.RawMessage) (any, error)
+
+// daemonMethod 将带参数的处理函数包装为 daemonHandler
+func daemonMethod[T any](fn func(T) (any, error)) daemonHandler {
+	return func(params json.RawMessage) (any, error) {
+		var p T
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &p); err != nil {
+				return nil, fmt.Errorf("解析参数失败: %w", err)
+			}
+		}
+		return fn(p)
+	}
+}
+
+type udidParams struct {
+	UDID string `json:"udid"`
+}
+
+type setLocationParams struct {
+	UDID string  `json:"udid"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+}
+
+type subscribeParams struct {
+	Events []string `json:"events"`
+}
+
+const (
+	// daemonWriteTimeout 单条消息写入连接的最长时间，超时后断开连接
+	daemonWriteTimeout = 5 * time.Second
+	// daemonEventQueue 每个订阅连接待发送事件的上限，客户端读取过慢导致积压超过上限时断开连接
+	daemonEventQueue = 256
+)
+
+// DaemonServer 守护进程服务端，长期持有隧道和位置模拟会话
+type DaemonServer struct {
+	devices  *DevicesService
+	location *LocationService
+	bus      *EventBus
+	handlers map[string]daemonHandler
+}
+
+// NewDaemonServer 创建守护进程服务端，bus 为上述服务发送事件所用的事件总线
+func NewDaemonServer(devices *DevicesService, location *LocationService, bus *EventBus) *DaemonServer {
+	s := &DaemonServer{
+		devices:  devices,
+		location: location,
+		bus:      bus,
+	}
+	s.handlers = map[string]daemonHandler{
+		"Ping": func(json.RawMessage) (any, error) {
+			return "pong", nil
+		},
+		"ListDevices": func(json.RawMessage) (any, error) {
+			return s.devices.ListDevices()
+		},
+		"SelectDevice": daemonMethod(func(p udidParams) (any, error) {
+			return nil, s.devices.SelectDevice(p.UDID)
+		}),
+		"GetSelectedDevice": func(json.RawMessage) (any, error) {
+			return s.devices.GetSelectedDevice()
+		},
+		"SetLocation": daemonMethod(func(p setLocationParams) (any, error) {
+			return nil, s.location.SetLocation(p.UDID, p.Lat, p.Lon)
+		}),
+		"ResetLocation": daemonMethod(func(p udidParams) (any, error) {
+			return nil, s.location.ResetLocation(p.UDID)
+		}),
+	}
+	return s
+}
+
+// Serve 在 path 上监听并处理客户端请求，直到 ctx 取消
+func (s *DaemonServer) Serve(ctx context.Context, path string) error {
+	if err := prepareSocketDir(path); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		// 套接字文件已存在：能连上说明已有守护进程在运行，否则是上次遗留的文件
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = conn.Close()
+			return fmt.Errorf("守护进程已在运行: %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("清理遗留套接字失败: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("监听套接字失败: %w", err)
+	}
+	_ = os.Chmod(path, 0o600)
+	Log.Info("DaemonService", "守护进程已启动: "+path)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := make(map[net.Conn]struct{})
+
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+		mu.Lock()
+		for conn := range conns {
+			_ = conn.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			Log.Error("DaemonService", "接受连接失败: "+err.Error())
+			continue
+		}
+
+		mu.Lock()
+		conns[conn] = struct{}{}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handleConn(conn)
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	_ = os.Remove(path)
+	Log.Info("DaemonService", "守护进程已退出")
+	return nil
+}
+
+// handleConn 处理单个客户端连接
+//
+// 事件先放入连接自己的队列，由单独的协程写出，事件总线分发时不会因为某个客户端读取过慢而阻塞跑步循环。
+func (s *DaemonServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	var writeMu sync.Mutex
+	enc := json.NewEncoder(conn)
+	write := func(msg daemonMessage) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_ = conn.SetWriteDeadline(time.Now().Add(daemonWriteTimeout))
+		if err := enc.Encode(msg); err != nil {
+			// 写入超时或失败后连接已不可用，关闭后读取循环随之退出
+			_ = conn.Close()
+		}
+	}
+
+	events := make(chan daemonMessage, daemonEventQueue)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case msg := <-events:
+				write(msg)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var overflow sync.Once
+	var unsubscribe func()
+	defer func() {
+		if unsubscribe != nil {
+			unsubscribe()
+		}
+	}()
+
+	dec := json.NewDecoder(conn)
+	for {
+		var req daemonMessage
+		if err := dec.Decode(&req); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				Log.Debug("DaemonService", "读取请求失败: "+err.Error())
+			}
+			return
+		}
+
+		resp := daemonMessage{ID: req.ID}
+		if req.Method == "Subscribe" {
+			var p subscribeParams
+			if len(req.Params) > 0 {
+				_ = json.Unmarshal(req.Params, &p)
+			}
+			if unsubscribe != nil {
+				unsubscribe()
+			}
+			unsubscribe = s.bus.Subscribe(func(name string, data any) {
+				raw, err := json.Marshal(data)
+				if err != nil {
+					return
+				}
+				select {
+				case events <- daemonMessage{Event: name, Data: raw}:
+				default:
+					overflow.Do(func() {
+						Log.Warn("DaemonService", "客户端读取事件过慢，断开连接")
+						_ = conn.Close()
+					})
+				}
+			}, p.Events...)
+			write(resp)
+			continue
+		}
+
+		handler, ok := s.handlers[req.Method]
+		if !ok {
+			resp.Error = "未知方法: " + req.Method
+			write(resp)
+			continue
+		}
+
+		// 耗时请求（如选择设备）不阻塞同一连接上的其他请求
+		go func() {
+			result, err := handler(req.Params)
+			if err != nil {
+				resp.Error = err.Error()
+			} else if result != nil {
+				raw, err := json.Marshal(result)
+				if err != nil {
+					resp.Error = err.Error()
+				} else {
+					resp.Result = raw
+				}
+			}
+			write(resp)
+		}()
+	}
+}
+
+// ErrDaemonUnavailable 与守护进程的连接已断开且无法重新连接，守护进程持有的位置会话已随之结束
+var ErrDaemonUnavailable = errors.New("守护进程不可用，请重新运行 iOSGhostRun daemon")
+
+// errDaemonSend 请求未能写入连接，守护进程没有收到，可以重新连接后重发
+var errDaemonSend = errors.New("发送请求失败")
+
+// DaemonClient 守护进程客户端
+//
+// DaemonClient 实现了 LocationBackend，位置会从守护进程持有的会话下发。
+// 连接断开后下一次调用会重新连接，守护进程重启时恢复事件订阅；无法重新连接时返回 ErrDaemonUnavailable。
+type DaemonClient struct {
+	path        string
+	writeMu     sync.Mutex
+	reconnectMu sync.Mutex
+	nextID      atomic.Int64
+
+	mu         sync.Mutex
+	conn       net.Conn
+	enc        *json.Encoder
+	pending    map[int64]chan daemonMessage
+	broken     bool // 连接已断开，下次调用时重新连接
+	closed     bool // 已调用 Close
+	subscribed bool
+	events     *EventBus
+}
+
+// DialDaemon 连接守护进程
+func DialDaemon(path string) (*DaemonClient, error) {
+	c := &DaemonClient{path: path, events: NewEventBus()}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect 建立连接并确认守护进程可用
+func (c *DaemonClient) connect() error {
+	conn, err := net.DialTimeout("unix", c.path, time.Second)
+	if err != nil {
+		return fmt.Errorf("连接守护进程失败: %w", err)
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.enc = json.NewEncoder(conn)
+	c.pending = make(map[int64]chan daemonMessage)
+	c.broken = false
+	c.mu.Unlock()
+	go c.readLoop(conn)
+
+	if err := c.roundTrip("Ping", nil, nil); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	return nil
+}
+
+// reconnect 连接已断开时重新连接，并恢复之前的事件订阅
+func (c *DaemonClient) reconnect() error {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+
+	c.mu.Lock()
+	broken, closed, subscribed := c.broken, c.closed, c.subscribed
+	c.mu.Unlock()
+	if closed {
+		return fmt.Errorf("守护进程连接已关闭")
+	}
+	if !broken {
+		return nil
+	}
+
+	if err := c.connect(); err != nil {
+		Log.Error("DaemonService", "重新连接守护进程失败: "+err.Error())
+		return fmt.Errorf("%w: %v", ErrDaemonUnavailable, err)
+	}
+	Log.Info("DaemonService", "已重新连接守护进程")
+	if subscribed {
+		if err := c.roundTrip("Subscribe", subscribeParams{}, nil); err != nil {
+			return fmt.Errorf("恢复事件订阅失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// readLoop 读取 conn 上的响应和事件，连接断开后结束其上未完成的请求
+func (c *DaemonClient) readLoop(conn net.Conn) {
+	dec := json.NewDecoder(conn)
+	for {
+		var msg daemonMessage
+		if err := dec.Decode(&msg); err != nil {
+			c.markBroken(conn)
+			return
+		}
+
+		if msg.Event != "" {
+			c.events.Emit(msg.Event, decodeDaemonEvent(msg.Event, msg.Data))
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[msg.ID]
+		delete(c.pending, msg.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+	}
+}
+
+// markBroken 标记 conn 已断开，并结束其上未完成的请求
+func (c *DaemonClient) markBroken(conn net.Conn) {
+	_ = conn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != conn || c.broken {
+		return
+	}
+	c.broken = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// decodeDaemonEvent 将事件数据还原为本进程内使用的类型
+func decodeDaemonEvent(name string, raw json.RawMessage) any {
+	switch name {
+	case EventRunningStarted, EventRunningPosition, EventRunningCompleted:
+		var status RunningStatus
+		if json.Unmarshal(raw, &status) == nil {
+			return status
+		}
+	case EventRunningInterval:
+		var interval IntervalStatus
+		if json.Unmarshal(raw, &interval) == nil {
+			return interval
+		}
+	case EventLog:
+		var entry LogEntry
+		if json.Unmarshal(raw, &entry) == nil {
+			return entry
+		}
+	default:
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return s
+		}
+	}
+	return raw
+}
+
+// call 调用守护进程方法，result 非 nil 时解析返回值；连接已断开时先重新连接
+func (c *DaemonClient) call(method string, params any, result any) error {
+	for retried := false; ; retried = true {
+		if err := c.reconnect(); err != nil {
+			return err
+		}
+		err := c.roundTrip(method, params, result)
+		// 守护进程退出后 readLoop 可能还没发现连接断开，请求写入失败时重新连接再发一次
+		if retried || !errors.Is(err, errDaemonSend) {
+			return err
+		}
+	}
+}
+
+// roundTrip 在当前连接上发送请求并等待响应
+func (c *DaemonClient) roundTrip(method string, params any, result any) error {
+	msg := daemonMessage{ID: c.nextID.Add(1), Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		msg.Params = raw
+	}
+
+	ch := make(chan daemonMessage, 1)
+	c.mu.Lock()
+	if c.closed || c.broken {
+		c.mu.Unlock()
+		return fmt.Errorf("守护进程连接已断开")
+	}
+	c.pending[msg.ID] = ch
+	conn, enc := c.conn, c.enc
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	err := enc.Encode(msg)
+	c.writeMu.Unlock()
+	if err != nil {
+		c.markBroken(conn)
+		return fmt.Errorf("%w: %v", errDaemonSend, err)
+	}
+
+	resp, ok := <-ch
+	if !ok {
+		return fmt.Errorf("守护进程连接已断开")
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	if result != nil && len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("解析返回值失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Subscribe 订阅守护进程事件，names 为空时订阅全部事件
+//
+// 回调在读取连接的协程中执行，不能在回调中同步调用 DaemonClient 的方法。
+func (c *DaemonClient) Subscribe(handler EventHandler, names ...string) (func(), error) {
+	unsubscribe := c.events.Subscribe(handler, names...)
+	// 守护进程端按连接订阅全部事件，本地再按订阅者过滤
+	if err := c.call("Subscribe", subscribeParams{}, nil); err != nil {
+		unsubscribe()
+		return nil, err
+	}
+	c.mu.Lock()
+	c.subscribed = true
+	c.mu.Unlock()
+	return unsubscribe, nil
+}
+
+func (c *DaemonClient) ListDevices() ([]DeviceInfo, error) {
+	var list []DeviceInfo
+	err := c.call("ListDevices", nil, &list)
+	return list, err
+}
+
+func (c *DaemonClient) SelectDevice(udid string) error {
+	return c.call("SelectDevice", udidParams{UDID: udid}, nil)
+}
+
+func (c *DaemonClient) GetSelectedDevice() (*DeviceInfo, error) {
+	var info DeviceInfo
+	if err := c.call("GetSelectedDevice", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (c *DaemonClient) SetLocation(udid string, lat, lon float64) error {
+	return c.call("SetLocation", setLocationParams{UDID: udid, Lat: lat, Lon: lon}, nil)
+}
+
+func (c *DaemonClient) ResetLocation(udid string) error {
+	return c.call("ResetLocation", udidParams{UDID: udid}, nil)
+}
+
+// Close 断开与守护进程的连接，守护进程持有的会话保持不变
+func (c *DaemonClient) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	conn := c.conn
+	c.mu.Unlock()
+	return conn.Close()
+}
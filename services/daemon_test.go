@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonSlowSubscriberDoesNotBlockEmit(t *testing.T) {
+	NewLoggerService(nil)
+	bus := NewEventBus()
+	server := NewDaemonServer(nil, nil, bus)
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		server.handleConn(serverConn)
+		close(closed)
+	}()
+
+	if err := json.NewEncoder(clientConn).Encode(daemonMessage{ID: 1, Method: "Subscribe"}); err != nil {
+		t.Fatalf("发送订阅请求失败: %v", err)
+	}
+	var resp daemonMessage
+	if err := json.NewDecoder(clientConn).Decode(&resp); err != nil || resp.ID != 1 {
+		t.Fatalf("订阅响应 = %+v，错误 %v", resp, err)
+	}
+
+	// 客户端不再读取，net.Pipe 没有缓冲，每次写入都会阻塞
+	emitted := make(chan struct{})
+	go func() {
+		for i := range 2 * daemonEventQueue {
+			bus.Emit(EventRunningPosition, i)
+		}
+		close(emitted)
+	}()
+	select {
+	case <-emitted:
+	case <-time.After(time.Second):
+		t.Fatal("客户端不读取时 Emit 被阻塞")
+	}
+
+	// 队列溢出后断开连接
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("事件积压后未断开连接")
+	}
+}
+
+// startDaemon 在 path 上启动只带内存位置后端的守护进程，返回停止函数
+func startDaemon(t *testing.T, path string, backend *MemoryLocationBackend) (*EventBus, func()) {
+	t.Helper()
+	bus := NewEventBus()
+	server := NewDaemonServer(nil, NewLocationServiceWithBackend(backend), bus)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		if err := server.Serve(ctx, path); err != nil {
+			t.Errorf("守护进程退出: %v", err)
+		}
+	}()
+	// 等待套接字可连接
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("守护进程未启动")
+		}
+	}
+	return bus, func() {
+		cancel()
+		<-served
+	}
+}
+
+// daemonSocketPath 返回临时套接字路径，Unix 套接字路径长度有限，不使用 t.TempDir 的长路径
+func daemonSocketPath(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "ghostrun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return filepath.Join(dir, "d.sock")
+}
+
+func TestDaemonClientReconnect(t *testing.T) {
+	NewLoggerService(nil)
+	path := daemonSocketPath(t)
+	_, stop := startDaemon(t, path, NewMemoryLocationBackend())
+
+	client, err := DialDaemon(path)
+	if err != nil {
+		t.Fatalf("连接守护进程失败: %v", err)
+	}
+	defer client.Close()
+	received := make(chan any, 1)
+	if _, err := client.Subscribe(func(name string, data any) { received <- data }, EventDeveloperModeRevealed); err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+
+	// 守护进程退出后无法重新连接
+	stop()
+	if err := client.SetLocation("test-device", 30, 120); !errors.Is(err, ErrDaemonUnavailable) {
+		t.Fatalf("守护进程退出后错误 = %v，期望 ErrDaemonUnavailable", err)
+	}
+
+	// 守护进程重启后重新连接并恢复订阅
+	backend := NewMemoryLocationBackend()
+	bus, stop := startDaemon(t, path, backend)
+	defer stop()
+	if err := client.SetLocation("test-device", 30, 120); err != nil {
+		t.Fatalf("守护进程重启后下发位置失败: %v", err)
+	}
+	if p, ok := backend.Current("test-device"); !ok || p.Lat != 30 || p.Lon != 120 {
+		t.Errorf("重启后的守护进程当前位置 = %+v, %v", p, ok)
+	}
+	bus.Emit(EventDeveloperModeRevealed, "重启后的事件")
+	select {
+	case data := <-received:
+		if data != "重启后的事件" {
+			t.Errorf("收到事件数据 %v", data)
+		}
+	case <-time.After(time.Second):
+		t.Error("重新连接后未恢复事件订阅")
+	}
+}
+
+func TestRunAbortsWhenDaemonGone(t *testing.T) {
+	NewLoggerService(nil)
+	path := daemonSocketPath(t)
+	_, stop := startDaemon(t, path, NewMemoryLocationBackend())
+	client, err := DialDaemon(path)
+	if err != nil {
+		t.Fatalf("连接守护进程失败: %v", err)
+	}
+	defer client.Close()
+
+	events := &eventRecorder{}
+	clock := NewManualClock(time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC))
+	service := NewRunningServiceWithClock(client, events, clock)
+	if err := service.StartRun("test-device", squareRoute(), 12); err != nil {
+		t.Fatalf("开始跑步失败: %v", err)
+	}
+	defer service.StopRun()
+	for range 10 {
+		clock.Advance(100 * time.Millisecond)
+	}
+
+	stop()
+	for range 10 {
+		clock.Advance(100 * time.Millisecond)
+	}
+
+	if state := service.GetStatus().State; state != StateIdle {
+		t.Errorf("守护进程退出后跑步状态 = %s，期望 idle", state)
+	}
+	aborted := events.named(EventRunningAborted)
+	if len(aborted) != 1 {
+		t.Fatalf("收到 %d 次终止事件，期望 1 次", len(aborted))
+	}
+	if msg, _ := aborted[0].(string); !strings.Contains(msg, "守护进程不可用") {
+		t.Errorf("终止原因 = %v", aborted[0])
+	}
+}
@@ -13,16 +13,33 @@ type DevicesService struct {
 	mu           sync.RWMutex
 	selectedUDID string
 	deviceInfo   map[string]DeviceInfo
+	events       EventSink
+	daemon       *DaemonClient
 }
 
-func NewDevicesService() *DevicesService {
+func NewDevicesService(events EventSink) *DevicesService {
+	if events == nil {
+		events = discardEvents{}
+	}
 	return &DevicesService{
 		deviceInfo: make(map[string]DeviceInfo),
+		events:     events,
 	}
 }
 
+// NewDevicesServiceWithDaemon 创建依附于守护进程的设备服务，隧道和镜像挂载均由守护进程负责
+func NewDevicesServiceWithDaemon(events EventSink, daemon *DaemonClient) *DevicesService {
+	d := NewDevicesService(events)
+	d.daemon = daemon
+	return d
+}
+
 // ListDevices 获取可连接设备列表
 func (d *DevicesService) ListDevices() ([]DeviceInfo, error) {
+	if d.daemon != nil {
+		return d.daemon.ListDevices()
+	}
+
 	Log.Info("DevicesService", "列出已连接的设备...")
 	list, err := ios.ListDevices()
 	if err != nil {
@@ -75,6 +92,17 @@ func (d *DevicesService) SelectDevice(udid string) error {
 		return fmt.Errorf("udid required")
 	}
 
+	if d.daemon != nil {
+		if err := d.daemon.SelectDevice(udid); err != nil {
+			Log.Error("DevicesService", "选择设备 "+udid+" 失败: "+err.Error())
+			return err
+		}
+		d.mu.Lock()
+		d.selectedUDID = udid
+		d.mu.Unlock()
+		return nil
+	}
+
 	if _, err := ios.GetDevice(udid); err != nil {
 		Log.Error("DevicesService", "选择设备 "+udid+" 失败: "+err.Error())
 		return err
@@ -99,7 +127,7 @@ func (d *DevicesService) SelectDevice(udid string) error {
 	d.selectedUDID = udid
 	d.mu.Unlock()
 
-	if err := MountImage(udid); err != nil {
+	if err := MountImage(udid, d.events); err != nil {
 		return err
 	}
 
@@ -122,6 +150,10 @@ func (d *DevicesService) GetSelectedDevice() (*DeviceInfo, error) {
 	}
 	d.mu.RUnlock()
 
+	if d.daemon != nil {
+		return d.daemon.GetSelectedDevice()
+	}
+
 	info, err := GetDeviceInfo(udid)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"sync"
+)
+
+// 服务对外发送的事件名
+const (
+	EventRunningStarted        = "running:started"
+	EventRunningPosition       = "running:position"
+	EventRunningCompleted      = "running:completed"
+	EventRunningInterval       = "running:interval"
+	EventRunningError          = "running:error"
+	EventRunningAborted        = "running:aborted" // 位置无法继续下发而终止跑步，数据为错误信息
+	EventLog                   = "log-event"
+	EventDeveloperModeRevealed = "developer-mode-menu-revealed"
+)
+
+// EventSink 事件接收端，服务通过它向外发送事件
+type EventSink interface {
+	Emit(name string, data any)
+}
+
+// EventHandler 事件订阅回调
+type EventHandler func(name string, data any)
+
+// discardEvents 丢弃所有事件，用于未注入事件接收端的服务
+type discardEvents struct{}
+
+func (discardEvents) Emit(string, any) {}
+
+// EventBus 进程内事件总线，支持多个订阅者
+//
+// 事件按订阅顺序同步分发给订阅者，订阅者不应在回调中长时间阻塞。
+type EventBus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   []eventSubscription
+}
+
+type eventSubscription struct {
+	id      int
+	names   map[string]struct{}
+	handler EventHandler
+}
+
+// NewEventBus 创建事件总线
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe 订阅事件，names 为空时订阅全部事件，返回取消订阅函数
+func (b *EventBus) Subscribe(handler EventHandler, names ...string) func() {
+	sub := eventSubscription{handler: handler}
+	if len(names) > 0 {
+		sub.names = make(map[string]struct{}, len(names))
+		for _, name := range names {
+			sub.names[name] = struct{}{}
+		}
+	}
+
+	b.mu.Lock()
+	b.nextID++
+	sub.id = b.nextID
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			for i, s := range b.subs {
+				if s.id == sub.id {
+					b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// Emit 向所有匹配的订阅者分发事件
+func (b *EventBus) Emit(name string, data any) {
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		if s.names != nil {
+			if _, ok := s.names[name]; !ok {
+				continue
+			}
+		}
+		s.handler(name, data)
+	}
+}
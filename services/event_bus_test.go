@@ -0,0 +1,81 @@
+package services
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestEventBusFilterByName(t *testing.T) {
+	bus := NewEventBus()
+	var all, positions []string
+	bus.Subscribe(func(name string, data any) { all = append(all, name) })
+	bus.Subscribe(func(name string, data any) { positions = append(positions, name) }, EventRunningPosition)
+
+	bus.Emit(EventRunningStarted, nil)
+	bus.Emit(EventRunningPosition, nil)
+	bus.Emit(EventLog, nil)
+
+	if want := []string{EventRunningStarted, EventRunningPosition, EventLog}; !slices.Equal(all, want) {
+		t.Errorf("订阅全部事件收到 %v，期望 %v", all, want)
+	}
+	if want := []string{EventRunningPosition}; !slices.Equal(positions, want) {
+		t.Errorf("按名称订阅收到 %v，期望 %v", positions, want)
+	}
+}
+
+func TestEventBusSubscribeDuringEmit(t *testing.T) {
+	bus := NewEventBus()
+	var got []string
+	record := func(who string) EventHandler {
+		return func(name string, data any) { got = append(got, who+":"+data.(string)) }
+	}
+
+	var unsubscribeA func()
+	unsubscribeA = bus.Subscribe(func(name string, data any) {
+		got = append(got, "a:"+data.(string))
+		// 分发中取消自己并订阅新的处理函数，不影响本次分发的其余订阅者
+		unsubscribeA()
+		bus.Subscribe(record("c"))
+	})
+	unsubscribeB := bus.Subscribe(record("b"))
+
+	bus.Emit("test", "1")
+	bus.Emit("test", "2")
+	unsubscribeB()
+	unsubscribeB() // 重复取消无影响
+	bus.Emit("test", "3")
+
+	want := []string{"a:1", "b:1", "b:2", "c:2", "c:3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("收到 %v，期望 %v", got, want)
+	}
+}
+
+func TestEventBusConcurrent(t *testing.T) {
+	bus := NewEventBus()
+	var mu sync.Mutex
+	count := 0
+	bus.Subscribe(func(string, any) {
+		mu.Lock()
+		count++
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				bus.Emit("test", nil)
+				unsubscribe := bus.Subscribe(func(string, any) {})
+				unsubscribe()
+			}
+		}()
+	}
+	wg.Wait()
+	if count != 800 {
+		t.Errorf("收到 %d 个事件，期望 800", count)
+	}
+}
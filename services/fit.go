@@ -0,0 +1,303 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// FIT 全局消息号
+const (
+	fitMesgFileID   = 0
+	fitMesgSession  = 18
+	fitMesgLap      = 19
+	fitMesgRecord   = 20
+	fitMesgEvent    = 21
+	fitMesgActivity = 34
+)
+
+// FIT 基本类型
+const (
+	fitEnum   = 0x00
+	fitUint16 = 0x84
+	fitSint32 = 0x85
+	fitUint32 = 0x86
+)
+
+const (
+	fitProtocolVersion = 0x20      // 2.0
+	fitProfileVersion  = 2132      // 21.32
+	fitEpoch           = 631065600 // 1989-12-31T00:00:00Z 的 Unix 时间
+	fitManufacturerDev = 255       // development
+	fitSportRunning    = 1
+)
+
+// trackPauseGap 相邻轨迹点间隔超过该值时视为暂停，不计入计时时间
+const trackPauseGap = 5 * time.Second
+
+// trackLap 按圈数拆分的轨迹
+type trackLap struct {
+	Points        []TrackPoint
+	Start         time.Time     // 开始时间，即上一圈结束时间
+	StartDistance float64       // 开始时的累计距离 km
+	Timer         time.Duration // 扣除暂停后的计时时间
+}
+
+func (l trackLap) end() TrackPoint { return l.Points[len(l.Points)-1] }
+
+func (l trackLap) elapsed() time.Duration { return l.end().Time.Sub(l.Start) }
+
+// distance 本圈距离 m
+func (l trackLap) distance() float64 { return (l.end().Distance - l.StartDistance) * 1000 }
+
+// avgSpeed 平均速度 m/s
+func (l trackLap) avgSpeed() float64 {
+	if l.Timer <= 0 {
+		return 0
+	}
+	return l.distance() / l.Timer.Seconds()
+}
+
+// maxSpeed 最大速度 m/s
+func (l trackLap) maxSpeed() float64 {
+	var max float64
+	for _, p := range l.Points {
+		max = math.Max(max, p.Speed/3.6)
+	}
+	return max
+}
+
+// splitTrackLaps 按 Lap 字段拆分轨迹，每圈从上一圈最后一个点开始计时
+func splitTrackLaps(track []TrackPoint) []trackLap {
+	var laps []trackLap
+	for i, p := range track {
+		if i == 0 || p.Lap != track[i-1].Lap {
+			lap := trackLap{Start: p.Time}
+			if i > 0 {
+				prev := track[i-1]
+				lap.Start = prev.Time
+				lap.StartDistance = prev.Distance
+			}
+			laps = append(laps, lap)
+		}
+
+		lap := &laps[len(laps)-1]
+		prevTime := lap.Start
+		if len(lap.Points) > 0 {
+			prevTime = lap.end().Time
+		}
+		if gap := p.Time.Sub(prevTime); gap > 0 && gap <= trackPauseGap {
+			lap.Timer += gap
+		}
+		lap.Points = append(lap.Points, p)
+	}
+	return laps
+}
+
+// WriteTrackFIT 将跑步轨迹写为 FIT 活动文件
+//
+// 包含 file_id、record、lap、session 和 activity 消息，每圈对应一个 lap；
+// 时间、距离和速度均取自实际下发到设备的轨迹点。
+func WriteTrackFIT(w io.Writer, track []TrackPoint) error {
+	if len(track) == 0 {
+		return fmt.Errorf("轨迹为空，无法导出")
+	}
+
+	laps := splitTrackLaps(track)
+	first, last := track[0], track[len(track)-1]
+
+	var e fitEncoder
+	e.write(fitMesgFileID, 0,
+		fitField{0, fitEnum, 4}, // type: activity
+		fitField{1, fitUint16, fitManufacturerDev},
+		fitField{2, fitUint16, 0},
+		fitField{4, fitUint32, fitTime(first.Time)},
+	)
+	e.write(fitMesgEvent, 1,
+		fitField{253, fitUint32, fitTime(first.Time)},
+		fitField{0, fitEnum, 0}, // event: timer
+		fitField{1, fitEnum, 0}, // event_type: start
+	)
+
+	for _, p := range track {
+		e.write(fitMesgRecord, 2,
+			fitField{253, fitUint32, fitTime(p.Time)},
+			fitField{0, fitSint32, fitSemicircles(p.Lat)},
+			fitField{1, fitSint32, fitSemicircles(p.Lon)},
+			fitField{5, fitUint32, fitScaled(p.Distance*1000, 100)},
+			fitField{6, fitUint16, fitScaled(p.Speed/3.6, 1000)},
+		)
+	}
+
+	e.write(fitMesgEvent, 1,
+		fitField{253, fitUint32, fitTime(last.Time)},
+		fitField{0, fitEnum, 0}, // event: timer
+		fitField{1, fitEnum, 4}, // event_type: stop_all
+	)
+
+	var timer time.Duration
+	var maxSpeed float64
+	for i, lap := range laps {
+		end := lap.end()
+		timer += lap.Timer
+		maxSpeed = math.Max(maxSpeed, lap.maxSpeed())
+		e.write(fitMesgLap, 3,
+			fitField{253, fitUint32, fitTime(end.Time)},
+			fitField{254, fitUint16, uint64(i)},
+			fitField{0, fitEnum, 9}, // event: lap
+			fitField{1, fitEnum, 1}, // event_type: stop
+			fitField{2, fitUint32, fitTime(lap.Start)},
+			fitField{3, fitSint32, fitSemicircles(lap.Points[0].Lat)},
+			fitField{4, fitSint32, fitSemicircles(lap.Points[0].Lon)},
+			fitField{5, fitSint32, fitSemicircles(end.Lat)},
+			fitField{6, fitSint32, fitSemicircles(end.Lon)},
+			fitField{7, fitUint32, fitScaled(lap.elapsed().Seconds(), 1000)},
+			fitField{8, fitUint32, fitScaled(lap.Timer.Seconds(), 1000)},
+			fitField{9, fitUint32, fitScaled(lap.distance(), 100)},
+			fitField{13, fitUint16, fitScaled(lap.avgSpeed(), 1000)},
+			fitField{14, fitUint16, fitScaled(lap.maxSpeed(), 1000)},
+			fitField{25, fitEnum, fitSportRunning},
+		)
+	}
+
+	distance := last.Distance * 1000
+	var avgSpeed float64
+	if timer > 0 {
+		avgSpeed = distance / timer.Seconds()
+	}
+	e.write(fitMesgSession, 4,
+		fitField{253, fitUint32, fitTime(last.Time)},
+		fitField{254, fitUint16, 0},
+		fitField{0, fitEnum, 8}, // event: session
+		fitField{1, fitEnum, 1}, // event_type: stop
+		fitField{2, fitUint32, fitTime(first.Time)},
+		fitField{3, fitSint32, fitSemicircles(first.Lat)},
+		fitField{4, fitSint32, fitSemicircles(first.Lon)},
+		fitField{5, fitEnum, fitSportRunning},
+		fitField{6, fitEnum, 0}, // sub_sport: generic
+		fitField{7, fitUint32, fitScaled(last.Time.Sub(first.Time).Seconds(), 1000)},
+		fitField{8, fitUint32, fitScaled(timer.Seconds(), 1000)},
+		fitField{9, fitUint32, fitScaled(distance, 100)},
+		fitField{14, fitUint16, fitScaled(avgSpeed, 1000)},
+		fitField{15, fitUint16, fitScaled(maxSpeed, 1000)},
+		fitField{25, fitUint16, 0},
+		fitField{26, fitUint16, uint64(len(laps))},
+	)
+
+	_, offset := last.Time.Zone()
+	e.write(fitMesgActivity, 5,
+		fitField{253, fitUint32, fitTime(last.Time)},
+		fitField{0, fitUint32, fitScaled(timer.Seconds(), 1000)},
+		fitField{1, fitUint16, 1},
+		fitField{2, fitEnum, 0},  // type: manual
+		fitField{3, fitEnum, 26}, // event: activity
+		fitField{4, fitEnum, 1},  // event_type: stop
+		fitField{5, fitUint32, fitTime(last.Time) + uint64(offset)},
+	)
+
+	return e.flush(w)
+}
+
+// fitField 消息字段，value 按基本类型的长度以小端序写入
+type fitField struct {
+	num      byte
+	baseType byte
+	value    uint64
+}
+
+func (f fitField) size() byte {
+	switch f.baseType {
+	case fitUint16:
+		return 2
+	case fitSint32, fitUint32:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// fitEncoder 按本地消息类型缓存定义，定义变化时重新写入定义消息
+type fitEncoder struct {
+	data    bytes.Buffer
+	defined [16]uint16
+	valid   [16]bool
+}
+
+func (e *fitEncoder) write(global uint16, local byte, fields ...fitField) {
+	if !e.valid[local] || e.defined[local] != global {
+		e.data.WriteByte(0x40 | local)
+		e.data.WriteByte(0) // reserved
+		e.data.WriteByte(0) // 小端序
+		_ = binary.Write(&e.data, binary.LittleEndian, global)
+		e.data.WriteByte(byte(len(fields)))
+		for _, f := range fields {
+			e.data.Write([]byte{f.num, f.size(), f.baseType})
+		}
+		e.defined[local] = global
+		e.valid[local] = true
+	}
+
+	e.data.WriteByte(local)
+	var buf [8]byte
+	for _, f := range fields {
+		binary.LittleEndian.PutUint64(buf[:], f.value)
+		e.data.Write(buf[:f.size()])
+	}
+}
+
+// flush 写入文件头、数据和文件 CRC
+func (e *fitEncoder) flush(w io.Writer) error {
+	header := make([]byte, 14)
+	header[0] = 14
+	header[1] = fitProtocolVersion
+	binary.LittleEndian.PutUint16(header[2:], fitProfileVersion)
+	binary.LittleEndian.PutUint32(header[4:], uint32(e.data.Len()))
+	copy(header[8:], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:], fitCRC(0, header[:12]))
+
+	crc := fitCRC(fitCRC(0, header), e.data.Bytes())
+	out := append(header, e.data.Bytes()...)
+	out = binary.LittleEndian.AppendUint16(out, crc)
+	if _, err := w.Write(out); err != nil {
+		return fmt.Errorf("写入 FIT 失败: %w", err)
+	}
+	return nil
+}
+
+var fitCRCTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+func fitCRC(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		tmp := fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[b&0xF]
+
+		tmp = fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[(b>>4)&0xF]
+	}
+	return crc
+}
+
+// fitTime FIT 时间戳：自 1989-12-31 起的秒数
+func fitTime(t time.Time) uint64 {
+	return uint64(uint32(t.Unix() - fitEpoch))
+}
+
+// fitSemicircles 角度转半圆单位
+func fitSemicircles(deg float64) uint64 {
+	return uint64(uint32(int64(math.Round(deg * (1 << 31) / 180))))
+}
+
+func fitScaled(v, scale float64) uint64 {
+	if v <= 0 {
+		return 0
+	}
+	return uint64(math.Round(v * scale))
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// lapTrack 两圈轨迹，每秒一个点，速度 10.8 km/h；第二圈中途暂停 30 秒
+func lapTrack() []TrackPoint {
+	start := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	var track []TrackPoint
+	steps := 0
+	add := func(sec, lap int) {
+		// 暂停期间不移动，恢复后的第一个点与暂停前位置相同
+		if n := len(track); n > 0 && track[n-1].Time.Add(time.Second).Equal(start.Add(time.Duration(sec)*time.Second)) {
+			steps++
+		}
+		track = append(track, TrackPoint{
+			Lat:      30 + float64(steps)*0.00003,
+			Lon:      120,
+			Time:     start.Add(time.Duration(sec) * time.Second),
+			Speed:    10.8,
+			Distance: float64(steps) * 0.003,
+			Lap:      lap,
+		})
+	}
+	for sec := 0; sec <= 10; sec++ {
+		add(sec, 1)
+	}
+	for sec := 11; sec <= 15; sec++ {
+		add(sec, 2)
+	}
+	for sec := 45; sec <= 50; sec++ {
+		add(sec, 2)
+	}
+	return track
+}
+
+// fitMessage 解码后的 FIT 数据消息
+type fitMessage struct {
+	global uint16
+	fields map[byte]uint64
+}
+
+// decodeFIT 校验文件头和 CRC，按定义消息解码全部数据消息，只支持本包写出的小端序、无压缩时间戳的文件
+func decodeFIT(data []byte) ([]fitMessage, error) {
+	if len(data) < 16 || data[0] != 14 || string(data[8:12]) != ".FIT" {
+		return nil, fmt.Errorf("文件头无效")
+	}
+	if crc := binary.LittleEndian.Uint16(data[12:]); crc != fitCRC(0, data[:12]) {
+		return nil, fmt.Errorf("文件头 CRC 不匹配")
+	}
+	size := int(binary.LittleEndian.Uint32(data[4:]))
+	if 14+size+2 != len(data) {
+		return nil, fmt.Errorf("数据长度 %d 与文件长度 %d 不符", size, len(data))
+	}
+	// 包含文件末尾 CRC 在内计算的 CRC 为 0
+	if fitCRC(0, data) != 0 {
+		return nil, fmt.Errorf("文件 CRC 不匹配")
+	}
+
+	type definition struct {
+		global uint16
+		fields [][3]byte
+	}
+	var defs [16]*definition
+	var messages []fitMessage
+	body := data[14 : 14+size]
+	for i := 0; i < len(body); {
+		header := body[i]
+		local := header & 0x0f
+		i++
+		if header&0x40 != 0 {
+			if i+5 > len(body) || body[i+1] != 0 {
+				return nil, fmt.Errorf("定义消息无效")
+			}
+			def := &definition{global: binary.LittleEndian.Uint16(body[i+2:])}
+			n := int(body[i+4])
+			i += 5
+			for range n {
+				def.fields = append(def.fields, [3]byte{body[i], body[i+1], body[i+2]})
+				i += 3
+			}
+			defs[local] = def
+			continue
+		}
+		def := defs[local]
+		if def == nil {
+			return nil, fmt.Errorf("本地消息 %d 未定义", local)
+		}
+		msg := fitMessage{global: def.global, fields: map[byte]uint64{}}
+		for _, f := range def.fields {
+			var buf [8]byte
+			copy(buf[:], body[i:i+int(f[1])])
+			msg.fields[f[0]] = binary.LittleEndian.Uint64(buf[:])
+			i += int(f[1])
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func TestWriteTrackFIT(t *testing.T) {
+	track := lapTrack()
+	var buf bytes.Buffer
+	if err := WriteTrackFIT(&buf, track); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	messages, err := decodeFIT(buf.Bytes())
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+
+	// 消息顺序：file_id、开始事件、全部 record、停止事件、每圈一个 lap、session、activity
+	var layout []uint16
+	for _, m := range messages {
+		layout = append(layout, m.global)
+	}
+	want := []uint16{fitMesgFileID, fitMesgEvent}
+	for range track {
+		want = append(want, fitMesgRecord)
+	}
+	want = append(want, fitMesgEvent, fitMesgLap, fitMesgLap, fitMesgSession, fitMesgActivity)
+	if fmt.Sprint(layout) != fmt.Sprint(want) {
+		t.Fatalf("消息顺序 = %v，期望 %v", layout, want)
+	}
+
+	for i, p := range track {
+		r := messages[2+i].fields
+		if r[253] != fitTime(p.Time) || int32(r[0]) != int32(fitSemicircles(p.Lat)) || r[5] != uint64(math.Round(p.Distance*1e5)) || r[6] != 3000 {
+			t.Errorf("第 %d 条 record = %v", i, r)
+		}
+	}
+
+	// 第一圈 10 秒；第二圈从第一圈结束算起共 40 秒，扣除 30 秒暂停后计时 10 秒
+	laps := messages[len(messages)-4 : len(messages)-2]
+	lapWant := []struct {
+		start             time.Time
+		elapsed, timer    float64 // s
+		distance, average float64 // m, m/s
+	}{
+		{track[0].Time, 10, 10, 30, 3},
+		{track[10].Time, 40, 10, 30, 3},
+	}
+	for i, w := range lapWant {
+		l := laps[i].fields
+		got := []uint64{l[254], l[2], l[7], l[8], l[9], l[13]}
+		exp := []uint64{uint64(i), fitTime(w.start), uint64(w.elapsed * 1000), uint64(w.timer * 1000), uint64(w.distance * 100), uint64(w.average * 1000)}
+		if fmt.Sprint(got) != fmt.Sprint(exp) {
+			t.Errorf("第 %d 圈 [序号 开始 总时间 计时 距离 均速] = %v，期望 %v", i+1, got, exp)
+		}
+	}
+
+	session := messages[len(messages)-2].fields
+	if session[7] != 50000 || session[8] != 20000 || session[9] != 6000 || session[26] != 2 {
+		t.Errorf("session 总时间 %d 计时 %d 距离 %d 圈数 %d，期望 50000 20000 6000 2", session[7], session[8], session[9], session[26])
+	}
+	if activity := messages[len(messages)-1].fields; activity[0] != 20000 || activity[1] != 1 {
+		t.Errorf("activity 计时 %d 会话数 %d，期望 20000 1", activity[0], activity[1])
+	}
+
+	if err := WriteTrackFIT(&buf, nil); err == nil {
+		t.Error("空轨迹应返回错误")
+	}
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// geoJSONObject GeoJSON 对象，FeatureCollection、Feature 与几何共用
+type geoJSONObject struct {
+	Type        string          `json:"type"`
+	Features    []geoJSONObject `json:"features,omitempty"`
+	Geometry    *geoJSONObject  `json:"geometry,omitempty"`
+	Geometries  []geoJSONObject `json:"geometries,omitempty"`
+	Properties  map[string]any  `json:"properties,omitempty"`
+	Coordinates json.RawMessage `json:"coordinates,omitempty"`
+}
+
+// ParseGeoJSON 解析 GeoJSON
+//
+// 支持 FeatureCollection、Feature、GeometryCollection 以及 LineString、MultiLineString 几何。
+// 每个线要素生成一条路线，MultiLineString 的各段依次连接，名称取自 name 属性；
+// 要素带有 coordTimes、labels、speeds（km/h）、dwells（s）属性时作为各点时间、名称、速度和停留时间。
+// Point 要素按顺序合并为一条航点路线，name 作为航点名称。
+func ParseGeoJSON(data []byte) ([]Route, error) {
+	var root geoJSONObject
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("解析 GeoJSON 失败: %w", err)
+	}
+
+	p := geoJSONParser{waypoints: Route{Name: "航点"}}
+	if err := p.object(root, nil); err != nil {
+		return nil, err
+	}
+
+	routes := p.routes
+	if len(p.waypoints.Points) > 0 {
+		routes = append(routes, p.waypoints)
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("GeoJSON 中没有 LineString、MultiLineString 或 Point")
+	}
+	return routes, nil
+}
+
+type geoJSONParser struct {
+	routes    []Route
+	waypoints Route
+}
+
+func (p *geoJSONParser) object(obj geoJSONObject, props map[string]any) error {
+	switch obj.Type {
+	case "FeatureCollection":
+		for _, f := range obj.Features {
+			if err := p.object(f, nil); err != nil {
+				return err
+			}
+		}
+	case "Feature":
+		if obj.Geometry != nil {
+			return p.object(*obj.Geometry, obj.Properties)
+		}
+	case "GeometryCollection":
+		for _, g := range obj.Geometries {
+			if err := p.object(g, props); err != nil {
+				return err
+			}
+		}
+	case "LineString":
+		var coords [][]float64
+		if err := json.Unmarshal(obj.Coordinates, &coords); err != nil {
+			return fmt.Errorf("解析 LineString 坐标失败: %w", err)
+		}
+		return p.line([][][]float64{coords}, props)
+	case "MultiLineString":
+		var lines [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &lines); err != nil {
+			return fmt.Errorf("解析 MultiLineString 坐标失败: %w", err)
+		}
+		return p.line(lines, props)
+	case "Point":
+		var coord []float64
+		if err := json.Unmarshal(obj.Coordinates, &coord); err != nil {
+			return fmt.Errorf("解析 Point 坐标失败: %w", err)
+		}
+		point, err := geoJSONPosition(coord)
+		if err != nil {
+			return err
+		}
+		point.Label = geoJSONName(props)
+		p.waypoints.Points = append(p.waypoints.Points, point)
+	case "MultiPoint", "Polygon", "MultiPolygon":
+		// 面和多点不是路线，忽略
+	default:
+		return fmt.Errorf("不支持的 GeoJSON 类型: %s", obj.Type)
+	}
+	return nil
+}
+
+func (p *geoJSONParser) line(lines [][][]float64, props map[string]any) error {
+	times := geoJSONStrings(props["coordTimes"])
+	labels := geoJSONStrings(props["labels"])
+	speeds := geoJSONNumbers(props["speeds"])
+	dwells := geoJSONNumbers(props["dwells"])
+
+	route := Route{Name: geoJSONName(props)}
+	for _, line := range lines {
+		for _, coord := range line {
+			point, err := geoJSONPosition(coord)
+			if err != nil {
+				return err
+			}
+			i := len(route.Points)
+			if i < len(times) {
+				if t, ok := parseGPXTime(times[i]); ok {
+					point.Time = t
+				}
+			}
+			if i < len(labels) {
+				point.Label = labels[i]
+			}
+			if i < len(speeds) && speeds[i] > 0 {
+				point.Speed = speeds[i]
+			}
+			if i < len(dwells) && dwells[i] > 0 {
+				point.Dwell = dwells[i]
+			}
+			route.Points = append(route.Points, point)
+		}
+	}
+	if len(route.Points) == 0 {
+		return nil
+	}
+	if route.Name == "" {
+		route.Name = fmt.Sprintf("路线 %d", len(p.routes)+1)
+	}
+	p.routes = append(p.routes, route)
+	return nil
+}
+
+// geoJSONPosition 解析 [经度, 纬度, 海拔?]
+func geoJSONPosition(coord []float64) (Point, error) {
+	if len(coord) < 2 {
+		return Point{}, fmt.Errorf("无效的 GeoJSON 坐标: %v", coord)
+	}
+	point := Point{Lat: coord[1], Lon: coord[0]}
+	if len(coord) >= 3 {
+		point.Ele = coord[2]
+	}
+	return point, nil
+}
+
+// geoJSONStrings 读取字符串数组属性，非字符串元素视为空串
+func geoJSONStrings(v any) []string {
+	raw, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	out := make([]string, len(raw))
+	for i, item := range raw {
+		out[i], _ = item.(string)
+	}
+	return out
+}
+
+// geoJSONNumbers 读取数值数组属性，非数值元素视为 0
+func geoJSONNumbers(v any) []float64 {
+	raw, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	out := make([]float64, len(raw))
+	for i, item := range raw {
+		out[i], _ = item.(float64)
+	}
+	return out
+}
+
+func geoJSONName(props map[string]any) string {
+	for _, key := range []string{"name", "title"} {
+		if s, ok := props[key].(string); ok && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+// geoJSONFeature 导出用的 Feature
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// WriteRoutesGeoJSON 将路线写为 FeatureCollection，每条路线一个 LineString 要素
+//
+// 要素属性包含 name、distance（km），点带时间时附加 coordTimes，带名称时附加 labels，
+// 带速度或停留时间时附加 speeds（km/h）、dwells（s），未设置的点为 0。
+func WriteRoutesGeoJSON(w io.Writer, routes []Route) error {
+	fc := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	for _, route := range routes {
+		coords := make([][]float64, 0, len(route.Points))
+		var times, labels []string
+		var speeds, dwells []float64
+		hasTime, hasLabel, hasSpeed, hasDwell := false, false, false, false
+		for _, p := range route.Points {
+			coords = append(coords, geoJSONCoord(p.Lat, p.Lon, p.Ele))
+			var ts string
+			if !p.Time.IsZero() {
+				ts = p.Time.UTC().Format(time.RFC3339Nano)
+				hasTime = true
+			}
+			times = append(times, ts)
+			labels = append(labels, p.Label)
+			if p.Label != "" {
+				hasLabel = true
+			}
+			speeds = append(speeds, p.Speed)
+			dwells = append(dwells, p.Dwell)
+			hasSpeed = hasSpeed || p.Speed > 0
+			hasDwell = hasDwell || p.Dwell > 0
+		}
+
+		props := map[string]any{
+			"name":     route.Name,
+			"distance": routeDistance(route.Points),
+		}
+		if hasTime {
+			props["coordTimes"] = times
+		}
+		if hasLabel {
+			props["labels"] = labels
+		}
+		if hasSpeed {
+			props["speeds"] = speeds
+		}
+		if hasDwell {
+			props["dwells"] = dwells
+		}
+		fc.Features = append(fc.Features, geoJSONFeature{
+			Type:       "Feature",
+			Geometry:   geoJSONGeometry{Type: "LineString", Coordinates: coords},
+			Properties: props,
+		})
+	}
+	return writeGeoJSON(w, fc)
+}
+
+// WriteTrackGeoJSON 将跑步轨迹写为包含单个 LineString 要素的 FeatureCollection
+//
+// 要素属性包含 name、distance（km）、startTime、endTime、durationMs，
+// 以及与坐标一一对应的 coordTimes、speeds（km/h）和 distances（km）。
+func WriteTrackGeoJSON(w io.Writer, name string, track []TrackPoint) error {
+	coords := make([][]float64, 0, len(track))
+	times := make([]string, 0, len(track))
+	speeds := make([]float64, 0, len(track))
+	distances := make([]float64, 0, len(track))
+	for _, p := range track {
+		coords = append(coords, geoJSONCoord(p.Lat, p.Lon, 0))
+		times = append(times, p.Time.UTC().Format(time.RFC3339Nano))
+		speeds = append(speeds, p.Speed)
+		distances = append(distances, p.Distance)
+	}
+
+	props := map[string]any{
+		"name":       name,
+		"coordTimes": times,
+		"speeds":     speeds,
+		"distances":  distances,
+	}
+	if len(track) > 0 {
+		first, last := track[0], track[len(track)-1]
+		props["distance"] = last.Distance
+		props["startTime"] = first.Time.UTC().Format(time.RFC3339Nano)
+		props["endTime"] = last.Time.UTC().Format(time.RFC3339Nano)
+		props["durationMs"] = last.Time.Sub(first.Time).Milliseconds()
+	}
+
+	fc := geoJSONFeatureCollection{
+		Type: "FeatureCollection",
+		Features: []geoJSONFeature{{
+			Type:       "Feature",
+			Geometry:   geoJSONGeometry{Type: "LineString", Coordinates: coords},
+			Properties: props,
+		}},
+	}
+	return writeGeoJSON(w, fc)
+}
+
+func geoJSONCoord(lat, lon, ele float64) []float64 {
+	if ele != 0 {
+		return []float64{lon, lat, ele}
+	}
+	return []float64{lon, lat}
+}
+
+func writeGeoJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("生成 GeoJSON 失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGeoJSONRoutePointAttributes(t *testing.T) {
+	route := Route{Name: "配速路线", Points: []Point{
+		{Lat: 39.90, Lon: 116.39, Speed: 10},
+		{Lat: 39.91, Lon: 116.40, Dwell: 30, Label: "补给站"},
+		{Lat: 39.92, Lon: 116.41, Speed: 12.5},
+		{Lat: 39.93, Lon: 116.42},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteRoutesGeoJSON(&buf, []Route{route}); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	routes, err := ParseGeoJSON(buf.Bytes())
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if len(routes) != 1 || len(routes[0].Points) != len(route.Points) {
+		t.Fatalf("解析结果 = %+v", routes)
+	}
+	for i, want := range route.Points {
+		got := routes[0].Points[i]
+		if got.Speed != want.Speed || got.Dwell != want.Dwell || got.Label != want.Label {
+			t.Errorf("点 %d = %+v，期望 %+v", i, got, want)
+		}
+	}
+}
+
+func TestGeoJSONWithoutPointAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	route := Route{Name: "普通路线", Points: []Point{{Lat: 39.90, Lon: 116.39}, {Lat: 39.91, Lon: 116.40}}}
+	if err := WriteRoutesGeoJSON(&buf, []Route{route}); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	for _, key := range []string{"speeds", "dwells", "labels", "coordTimes"} {
+		if bytes.Contains(buf.Bytes(), []byte(`"`+key+`"`)) {
+			t.Errorf("未设置的属性 %s 不应导出", key)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// .ghostroute 路线文件格式
+//
+// UTF-8 编码的 JSON 对象，字段如下：
+//
+//	format       固定为 "ghostroute"
+//	version      格式版本，当前为 GhostRouteVersion
+//	name         路线名称
+//	coordSystem  points 所用坐标系："WGS84"、"GCJ02" 或 "BD09"，缺省为 WGS84
+//	points       路线点数组，每点包含 lat、lon，以及可选的
+//	             ele（海拔 m）、speed（该点起的速度 km/h）、dwell（到达后停留秒数）、
+//	             label（名称）、time（RFC 3339 时间戳）
+//	defaults     可选，默认跑步参数：speed（km/h）、speedVariance（km/h）、routeOffset（m）、loopCount，
+//	             缺省或为 0 的项不覆盖使用者的设置
+//	notes        可选，备注
+//	tags         可选，标签数组
+//	createdAt    可选，创建时间 RFC 3339
+//	modifiedAt   可选，修改时间 RFC 3339
+//
+// 读取时先按 version 依次执行迁移升级到当前版本，再将坐标转换为 WGS84；
+// 没有 version 字段的文件视为旧版 {name, points, createdAt} 路线（版本 0）。
+// 写出时字段顺序固定、缩进两格、末尾换行，便于提交到版本库后比较差异。
+
+// GhostRouteFormat .ghostroute 文件的 format 字段
+const GhostRouteFormat = "ghostroute"
+
+// GhostRouteVersion 当前 .ghostroute 格式版本
+const GhostRouteVersion = 1
+
+// GhostRouteExt .ghostroute 文件扩展名
+const GhostRouteExt = ".ghostroute"
+
+// RunParams 跑步参数，作为路线默认参数时零值表示不指定
+type RunParams struct {
+	Speed         float64 `json:"speed,omitempty"`         // km/h
+	SpeedVariance float64 `json:"speedVariance,omitempty"` // km/h
+	RouteOffset   float64 `json:"routeOffset,omitempty"`   // m
+	LoopCount     int     `json:"loopCount,omitempty"`     // 0 为无限
+}
+
+// GhostRoute .ghostroute 文件内容
+type GhostRoute struct {
+	Format      string      `json:"format"`
+	Version     int         `json:"version"`
+	Name        string      `json:"name"`
+	CoordSystem CoordSystem `json:"coordSystem"`
+	Points      []Point     `json:"points"`
+	Defaults    *RunParams  `json:"defaults,omitempty"`
+	Notes       string      `json:"notes,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	CreatedAt   time.Time   `json:"createdAt,omitzero"`
+	ModifiedAt  time.Time   `json:"modifiedAt,omitzero"`
+}
+
+// ghostRouteMigrations 第 i 项将版本 i 的文档升级到版本 i+1
+var ghostRouteMigrations = []func(doc map[string]any) error{
+	migrateGhostRouteV0,
+}
+
+// ParseGhostRoute 解析 .ghostroute 文件，升级到当前版本并将坐标转换为 WGS84
+func ParseGhostRoute(data []byte) (GhostRoute, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return GhostRoute{}, fmt.Errorf("解析路线文件失败: %w", err)
+	}
+	if doc == nil {
+		return GhostRoute{}, fmt.Errorf("路线文件必须是 JSON 对象")
+	}
+	if format, ok := doc["format"]; ok && format != GhostRouteFormat {
+		return GhostRoute{}, fmt.Errorf("不是 ghostroute 文件: format=%v", format)
+	}
+
+	version := 0
+	if v, ok := doc["version"]; ok {
+		f, ok := v.(float64)
+		if !ok || f != float64(int(f)) || f < 0 {
+			return GhostRoute{}, fmt.Errorf("无效的路线文件版本: %v", v)
+		}
+		version = int(f)
+	}
+	if version > GhostRouteVersion {
+		return GhostRoute{}, fmt.Errorf("路线文件版本 %d 高于当前支持的版本 %d，请升级应用", version, GhostRouteVersion)
+	}
+	for ; version < GhostRouteVersion; version++ {
+		if err := ghostRouteMigrations[version](doc); err != nil {
+			return GhostRoute{}, fmt.Errorf("升级路线文件到版本 %d 失败: %w", version+1, err)
+		}
+		doc["version"] = version + 1
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return GhostRoute{}, err
+	}
+	var route GhostRoute
+	if err := json.Unmarshal(migrated, &route); err != nil {
+		return GhostRoute{}, fmt.Errorf("解析路线文件失败: %w", err)
+	}
+
+	cs, err := ParseCoordSystem(string(route.CoordSystem))
+	if err != nil {
+		return GhostRoute{}, err
+	}
+	for i, p := range route.Points {
+		if p.Dwell < 0 || p.Speed < 0 {
+			return GhostRoute{}, fmt.Errorf("第 %d 个路线点的速度或停留时间为负数", i+1)
+		}
+	}
+	route.Points = TransformPoints(route.Points, cs, CoordWGS84)
+	route.CoordSystem = CoordWGS84
+	return route, nil
+}
+
+// WriteGhostRoute 写出 .ghostroute 文件，route 中的坐标为 WGS84，写出时转换为 to 坐标系
+func WriteGhostRoute(w io.Writer, route GhostRoute, to CoordSystem) error {
+	cs, err := ParseCoordSystem(string(to))
+	if err != nil {
+		return err
+	}
+
+	route.Format = GhostRouteFormat
+	route.Version = GhostRouteVersion
+	route.CoordSystem = cs
+	route.Points = TransformPoints(route.Points, CoordWGS84, cs)
+	if len(route.Tags) == 0 {
+		route.Tags = nil
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(route); err != nil {
+		return fmt.Errorf("生成路线文件失败: %w", err)
+	}
+	return nil
+}
+
+// migrateGhostRouteV0 旧版 {name, points, createdAt} 路线升级到版本 1
+//
+// createdAt、modifiedAt 为毫秒时间戳时转换为 RFC 3339，经度字段 lng 改名为 lon。
+func migrateGhostRouteV0(doc map[string]any) error {
+	doc["format"] = GhostRouteFormat
+	if _, ok := doc["coordSystem"]; !ok {
+		doc["coordSystem"] = string(CoordWGS84)
+	}
+	if name, _ := doc["name"].(string); strings.TrimSpace(name) == "" {
+		doc["name"] = "未命名路线"
+	}
+	for _, key := range []string{"createdAt", "modifiedAt"} {
+		if ms, ok := doc[key].(float64); ok {
+			if ms > 0 {
+				doc[key] = time.UnixMilli(int64(ms)).UTC().Format(time.RFC3339Nano)
+			} else {
+				delete(doc, key)
+			}
+		}
+	}
+
+	points, ok := doc["points"].([]any)
+	if !ok {
+		if doc["points"] != nil {
+			return fmt.Errorf("points 必须是数组")
+		}
+		doc["points"] = []any{}
+		return nil
+	}
+	for _, p := range points {
+		point, ok := p.(map[string]any)
+		if !ok {
+			return fmt.Errorf("路线点必须是对象")
+		}
+		if _, has := point["lon"]; !has {
+			if lng, has := point["lng"]; has {
+				point["lon"] = lng
+				delete(point, "lng")
+			}
+		}
+	}
+	return nil
+}
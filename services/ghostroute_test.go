@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGhostRouteV0Migration(t *testing.T) {
+	const v0 = `{"name":"晨跑","points":[{"lat":30,"lng":120},{"lat":30.001,"lon":120.001,"lng":99}],"createdAt":1714546800123,"modifiedAt":0}`
+	gr, err := ParseGhostRoute([]byte(v0))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if gr.Format != GhostRouteFormat || gr.Version != GhostRouteVersion || gr.CoordSystem != CoordWGS84 || gr.Name != "晨跑" {
+		t.Errorf("升级后 format=%q version=%d coordSystem=%q name=%q", gr.Format, gr.Version, gr.CoordSystem, gr.Name)
+	}
+	// 毫秒时间戳转换为时间，0 视为未设置
+	if want := time.Date(2024, 5, 1, 7, 0, 0, 123e6, time.UTC); !gr.CreatedAt.Equal(want) {
+		t.Errorf("createdAt = %v，期望 %v", gr.CreatedAt, want)
+	}
+	if !gr.ModifiedAt.IsZero() {
+		t.Errorf("modifiedAt = %v，期望未设置", gr.ModifiedAt)
+	}
+	// lng 改名为 lon，已有 lon 时保留 lon
+	want := []Point{{Lat: 30, Lon: 120}, {Lat: 30.001, Lon: 120.001}}
+	if len(gr.Points) != len(want) || gr.Points[0] != want[0] || gr.Points[1] != want[1] {
+		t.Errorf("路线点 = %+v，期望 %+v", gr.Points, want)
+	}
+}
+
+func TestParseGhostRouteV0Defaults(t *testing.T) {
+	gr, err := ParseGhostRoute([]byte(`{"name":"  "}`))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if gr.Name != "未命名路线" || gr.Points == nil || len(gr.Points) != 0 {
+		t.Errorf("升级后 name=%q points=%v", gr.Name, gr.Points)
+	}
+}
+
+func TestGhostRouteRoundTrip(t *testing.T) {
+	created := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	route := GhostRoute{
+		Name:       "环湖",
+		Points:     []Point{{Lat: 30.25, Lon: 120.15, Speed: 9, Label: "起点"}, {Lat: 30.26, Lon: 120.16, Dwell: 30}},
+		Defaults:   &RunParams{Speed: 10, LoopCount: 2},
+		Notes:      "周末",
+		Tags:       []string{"湖边"},
+		CreatedAt:  created,
+		ModifiedAt: created.Add(time.Hour),
+	}
+	for _, cs := range []CoordSystem{CoordWGS84, CoordGCJ02, CoordBD09} {
+		var buf bytes.Buffer
+		if err := WriteGhostRoute(&buf, route, cs); err != nil {
+			t.Fatalf("%s: 导出失败: %v", cs, err)
+		}
+		if !strings.Contains(buf.String(), `"coordSystem": "`+string(cs)+`"`) {
+			t.Errorf("%s: 导出内容未声明坐标系", cs)
+		}
+		got, err := ParseGhostRoute(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%s: 解析失败: %v", cs, err)
+		}
+		if got.Name != route.Name || got.Notes != route.Notes || *got.Defaults != *route.Defaults || !got.CreatedAt.Equal(created) || !got.ModifiedAt.Equal(route.ModifiedAt) {
+			t.Errorf("%s: 解析结果 = %+v", cs, got)
+		}
+		for i, p := range route.Points {
+			g := got.Points[i]
+			if d := haversine(p.Lat, p.Lon, g.Lat, g.Lon) * 1000; d > 0.001 || g.Speed != p.Speed || g.Dwell != p.Dwell || g.Label != p.Label {
+				t.Errorf("%s: 第 %d 个点 = %+v，期望 %+v（相差 %.4f m）", cs, i, g, p, d)
+			}
+		}
+	}
+}
+
+func TestParseGhostRouteErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"非对象", `[1,2]`, "解析路线文件失败"},
+		{"null", `null`, "必须是 JSON 对象"},
+		{"format 不符", `{"format":"gpx","version":1}`, "不是 ghostroute 文件"},
+		{"版本过高", `{"format":"ghostroute","version":2}`, "请升级应用"},
+		{"版本无效", `{"format":"ghostroute","version":1.5}`, "无效的路线文件版本"},
+		{"points 非数组", `{"name":"a","points":{}}`, "points 必须是数组"},
+		{"点非对象", `{"name":"a","points":[1]}`, "路线点必须是对象"},
+		{"负停留时间", `{"format":"ghostroute","version":1,"name":"a","points":[{"lat":30,"lon":120,"dwell":-1}]}`, "负数"},
+		{"未知坐标系", `{"format":"ghostroute","version":1,"name":"a","coordSystem":"UTM","points":[]}`, "不支持的坐标系"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseGhostRoute([]byte(c.data))
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("错误 = %v，期望包含 %q", err, c.err)
+			}
+		})
+	}
+}
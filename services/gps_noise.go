@@ -0,0 +1,229 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NoiseKind GPS 噪声模型种类
+type NoiseKind string
+
+const (
+	NoiseGaussian  NoiseKind = "gaussian"  // 白噪声，每次更新独立抖动
+	NoiseOU        NoiseKind = "ou"        // Ornstein-Uhlenbeck 相关随机游走，缓慢漂移并回归路线
+	NoiseMultipath NoiseKind = "multipath" // 多路径效应，偶发的短时大幅跳变
+)
+
+const (
+	// wanderInterval 横向偏移更新目标的间隔
+	wanderInterval = 3 * time.Second
+	// defaultOUTau OU 噪声默认相关时间 s
+	defaultOUTau = 30.0
+	// defaultBurstDuration 多路径跳变默认持续时间 s
+	defaultBurstDuration = 5.0
+	// defaultBurstRate 多路径跳变默认频率，次/分钟
+	defaultBurstRate = 0.5
+)
+
+// NoiseLayer 一层噪声配置
+type NoiseLayer struct {
+	Kind  NoiseKind `json:"kind"`
+	Sigma float64   `json:"sigma"`          // m，gaussian、ou 为每个方向的标准差，multipath 为跳变幅度
+	Tau   float64   `json:"tau,omitempty"`  // s，ou 为相关时间，multipath 为每次跳变的持续时间
+	Rate  float64   `json:"rate,omitempty"` // multipath 平均每分钟发生次数
+}
+
+// GPSNoiseConfig GPS 噪声配置，多层噪声叠加在 SetRandomization 的横向偏移之上
+type GPSNoiseConfig struct {
+	Layers []NoiseLayer `json:"layers"`
+}
+
+// NoiseModel GPS 噪声模型，按经过的时间推进，返回以前进方向为参照的偏移 m
+type NoiseModel interface {
+	Next(dt time.Duration) (along, right float64)
+}
+
+// Validate 检查噪声配置
+func (c GPSNoiseConfig) Validate() error {
+	for i, l := range c.Layers {
+		switch l.Kind {
+		case NoiseGaussian, NoiseOU, NoiseMultipath:
+		default:
+			return fmt.Errorf("第 %d 层噪声: 不支持的噪声模型 %q", i+1, l.Kind)
+		}
+		if l.Sigma < 0 || l.Tau < 0 || l.Rate < 0 {
+			return fmt.Errorf("第 %d 层噪声: 参数不能为负数", i+1)
+		}
+	}
+	return nil
+}
+
+// ParseGPSNoise 解析命令行噪声描述，各层以分号分隔，如
+// "ou:sigma=2,tau=20;multipath:sigma=15,rate=0.5"，sigma 可直接写在冒号后："gaussian:1.5"
+func ParseGPSNoise(spec string) ([]NoiseLayer, error) {
+	var layers []NoiseLayer
+	for part := range strings.SplitSeq(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kind, params, _ := strings.Cut(part, ":")
+		layer := NoiseLayer{Kind: NoiseKind(strings.ToLower(strings.TrimSpace(kind)))}
+		for kv := range strings.SplitSeq(params, ",") {
+			kv = strings.TrimSpace(kv)
+			if kv == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(kv, "=")
+			if !ok {
+				key, value = "sigma", kv
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("无效的噪声参数 %q", kv)
+			}
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "sigma":
+				layer.Sigma = v
+			case "tau":
+				layer.Tau = v
+			case "rate":
+				layer.Rate = v
+			default:
+				return nil, fmt.Errorf("未知的噪声参数 %q", key)
+			}
+		}
+		layers = append(layers, layer)
+	}
+	if err := (GPSNoiseConfig{Layers: layers}).Validate(); err != nil {
+		return nil, err
+	}
+	return layers, nil
+}
+
+// newNoiseModel 按配置创建噪声模型
+func newNoiseModel(l NoiseLayer, rng *rand.Rand) NoiseModel {
+	switch l.Kind {
+	case NoiseOU:
+		tau := l.Tau
+		if tau <= 0 {
+			tau = defaultOUTau
+		}
+		return &ouNoise{rng: rng, sigma: l.Sigma, tau: tau}
+	case NoiseMultipath:
+		duration, rate := l.Tau, l.Rate
+		if duration <= 0 {
+			duration = defaultBurstDuration
+		}
+		if rate <= 0 {
+			rate = defaultBurstRate
+		}
+		return &multipathNoise{rng: rng, magnitude: l.Sigma, duration: duration, rate: rate}
+	default:
+		return &gaussianNoise{rng: rng, sigma: l.Sigma}
+	}
+}
+
+// noiseStack 多层噪声叠加
+type noiseStack []NoiseModel
+
+func newNoiseStack(cfg GPSNoiseConfig, rng *rand.Rand) noiseStack {
+	stack := make(noiseStack, 0, len(cfg.Layers))
+	for _, l := range cfg.Layers {
+		stack = append(stack, newNoiseModel(l, rng))
+	}
+	return stack
+}
+
+func (s noiseStack) Next(dt time.Duration) (along, right float64) {
+	for _, m := range s {
+		a, r := m.Next(dt)
+		along += a
+		right += r
+	}
+	return along, right
+}
+
+// wanderNoise 横向偏移：每 3 秒取一个新的随机目标并平滑过渡，幅度可随时调整
+type wanderNoise struct {
+	rng       *rand.Rand
+	amplitude float64
+	offset    float64
+	elapsed   time.Duration
+}
+
+func (n *wanderNoise) Next(dt time.Duration) (float64, float64) {
+	if n.amplitude <= 0 {
+		n.offset = 0
+		return 0, 0
+	}
+	n.elapsed += dt
+	if n.elapsed > wanderInterval {
+		target := (n.rng.Float64()*2 - 1) * n.amplitude
+		n.offset = n.offset*0.7 + target*0.3
+		n.elapsed = 0
+	}
+	return 0, n.offset
+}
+
+// gaussianNoise 各方向独立的高斯白噪声
+type gaussianNoise struct {
+	rng   *rand.Rand
+	sigma float64
+}
+
+func (n *gaussianNoise) Next(time.Duration) (float64, float64) {
+	return n.rng.NormFloat64() * n.sigma, n.rng.NormFloat64() * n.sigma
+}
+
+// ouNoise Ornstein-Uhlenbeck 过程，平稳分布的标准差为 sigma，相关时间为 tau 秒
+type ouNoise struct {
+	rng        *rand.Rand
+	sigma, tau float64
+	x, y       float64
+	started    bool
+}
+
+func (n *ouNoise) Next(dt time.Duration) (float64, float64) {
+	if !n.started {
+		// 从平稳分布开始，避免每次跑步开头都贴着路线
+		n.x, n.y = n.rng.NormFloat64()*n.sigma, n.rng.NormFloat64()*n.sigma
+		n.started = true
+		return n.x, n.y
+	}
+	// 精确离散化，更新间隔变化时统计特性不变
+	decay := math.Exp(-dt.Seconds() / n.tau)
+	diffusion := n.sigma * math.Sqrt(1-decay*decay)
+	n.x = n.x*decay + diffusion*n.rng.NormFloat64()
+	n.y = n.y*decay + diffusion*n.rng.NormFloat64()
+	return n.x, n.y
+}
+
+// multipathNoise 多路径跳变：按泊松过程偶发，沿随机方向偏出后在持续时间内回落
+type multipathNoise struct {
+	rng                       *rand.Rand
+	magnitude, duration, rate float64
+	remaining                 float64 // 当前跳变剩余时间 s，0 表示没有跳变
+	dirX, dirY, peak          float64
+}
+
+func (n *multipathNoise) Next(dt time.Duration) (float64, float64) {
+	seconds := dt.Seconds()
+	if n.remaining <= 0 {
+		if n.rng.Float64() >= 1-math.Exp(-n.rate/60*seconds) {
+			return 0, 0
+		}
+		angle := n.rng.Float64() * 2 * math.Pi
+		n.dirX, n.dirY = math.Cos(angle), math.Sin(angle)
+		n.peak = n.magnitude * (0.5 + n.rng.Float64())
+		n.remaining = n.duration
+	}
+	n.remaining = math.Max(n.remaining-seconds, 0)
+	// 半个正弦周期：迅速偏出，再逐渐回到路线
+	shape := math.Sin(math.Pi * (1 - n.remaining/n.duration))
+	return n.dirX * n.peak * shape, n.dirY * n.peak * shape
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGPSNoise(t *testing.T) {
+	cases := []struct {
+		spec string
+		want []NoiseLayer
+	}{
+		{"", nil},
+		{" ; ", nil},
+		{"gaussian:1.5", []NoiseLayer{{Kind: NoiseGaussian, Sigma: 1.5}}},
+		{"gaussian", []NoiseLayer{{Kind: NoiseGaussian}}},
+		{"OU:Sigma=2, tau=20", []NoiseLayer{{Kind: NoiseOU, Sigma: 2, Tau: 20}}},
+		{"ou:sigma=2,tau=20;multipath:sigma=15,rate=0.5", []NoiseLayer{
+			{Kind: NoiseOU, Sigma: 2, Tau: 20},
+			{Kind: NoiseMultipath, Sigma: 15, Rate: 0.5},
+		}},
+		{"gaussian:1;gaussian:2;", []NoiseLayer{{Kind: NoiseGaussian, Sigma: 1}, {Kind: NoiseGaussian, Sigma: 2}}},
+	}
+	for _, c := range cases {
+		t.Run(c.spec, func(t *testing.T) {
+			got, err := ParseGPSNoise(c.spec)
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("ParseGPSNoise(%q) = %+v，期望 %+v", c.spec, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseGPSNoiseErrors(t *testing.T) {
+	cases := []struct {
+		spec string
+		err  string
+	}{
+		{"laser:1", "不支持的噪声模型"},
+		{"gaussian:abc", "无效的噪声参数"},
+		{"ou:sigma=", "无效的噪声参数"},
+		{"ou:speed=3", "未知的噪声参数"},
+		{"gaussian:-1", "不能为负数"},
+		{"gaussian:1;ou:tau=-5", "第 2 层噪声"},
+	}
+	for _, c := range cases {
+		t.Run(c.spec, func(t *testing.T) {
+			_, err := ParseGPSNoise(c.spec)
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("错误 = %v，期望包含 %q", err, c.err)
+			}
+		})
+	}
+}
+
+func TestNoiseStackSeedReplay(t *testing.T) {
+	cfg := GPSNoiseConfig{Layers: []NoiseLayer{
+		{Kind: NoiseGaussian, Sigma: 1},
+		{Kind: NoiseOU, Sigma: 2, Tau: 20},
+		{Kind: NoiseMultipath, Sigma: 15, Rate: 6},
+	}}
+	sample := func(seed int64) [][2]float64 {
+		stack := newNoiseStack(cfg, rand.New(rand.NewSource(seed)))
+		out := make([][2]float64, 600)
+		for i := range out {
+			out[i][0], out[i][1] = stack.Next(100 * time.Millisecond)
+		}
+		return out
+	}
+
+	first, second, other := sample(42), sample(42), sample(7)
+	if !reflect.DeepEqual(first, second) {
+		t.Error("相同种子得到了不同的噪声序列")
+	}
+	if reflect.DeepEqual(first, other) {
+		t.Error("不同种子得到了相同的噪声序列")
+	}
+}
+
+func TestOUNoiseStationarySigma(t *testing.T) {
+	const (
+		sigma  = 2.0
+		tau    = 10.0
+		chains = 2000
+		total  = 60 * time.Second
+		lag    = 10 * time.Second // 相关时间 tau，相关系数应为 1/e
+	)
+	for _, dt := range []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, time.Second, 5 * time.Second} {
+		rng := rand.New(rand.NewSource(1))
+		var sumSq, sumLagSq, sumProduct float64
+		for range chains {
+			n := newNoiseModel(NoiseLayer{Kind: NoiseOU, Sigma: sigma, Tau: tau}, rng)
+			var x, y, lagX, lagY float64
+			for elapsed := time.Duration(0); elapsed < total; elapsed += dt {
+				x, y = n.Next(dt)
+				if elapsed+dt == total-lag {
+					lagX, lagY = x, y
+				}
+			}
+			sumSq += x*x + y*y
+			sumLagSq += lagX*lagX + lagY*lagY
+			sumProduct += x*lagX + y*lagY
+		}
+
+		// 各方向的标准差与更新间隔无关，始终为 sigma
+		if std := math.Sqrt(sumSq / (2 * chains)); math.Abs(std-sigma)/sigma > 0.05 {
+			t.Errorf("间隔 %s: 标准差 %.3f m，期望 %.1f m", dt, std, sigma)
+		}
+		if corr := sumProduct / math.Sqrt(sumSq*sumLagSq); math.Abs(corr-math.Exp(-lag.Seconds()/tau)) > 0.05 {
+			t.Errorf("间隔 %s: 相隔 %s 的相关系数 %.3f，期望 %.3f", dt, lag, corr, math.Exp(-1))
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const gpxNamespace = "http://www.topografix.com/GPX/1/1"
+
+// gpxFile GPX 1.1 文档
+type gpxFile struct {
+	XMLName  xml.Name     `xml:"gpx"`
+	Xmlns    string       `xml:"xmlns,attr,omitempty"`
+	Version  string       `xml:"version,attr"`
+	Creator  string       `xml:"creator,attr"`
+	Metadata *gpxMetadata `xml:"metadata,omitempty"`
+	Wpts     []gpxPoint   `xml:"wpt"`
+	Rtes     []gpxRoute   `xml:"rte"`
+	Trks     []gpxTrack   `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name,omitempty"`
+	Time string `xml:"time,omitempty"`
+}
+
+type gpxPoint struct {
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Ele  *float64 `xml:"ele,omitempty"`
+	Time string   `xml:"time,omitempty"`
+	Name string   `xml:"name,omitempty"`
+}
+
+type gpxRoute struct {
+	Name   string     `xml:"name,omitempty"`
+	Points []gpxPoint `xml:"rtept"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+// ParseGPX 解析 GPX 1.1 文档
+//
+// 每条 trk（多个 trkseg 依次连接）和每条 rte 各生成一条路线；
+// 独立的 wpt 按文档顺序合并为一条名为"航点"的路线，排在最后。
+func ParseGPX(r io.Reader) ([]Route, error) {
+	var doc gpxFile
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("解析 GPX 失败: %w", err)
+	}
+
+	var routes []Route
+	for i, trk := range doc.Trks {
+		route := Route{Name: gpxRouteName(trk.Name, "轨迹", i)}
+		for _, seg := range trk.Segments {
+			for _, p := range seg.Points {
+				route.Points = append(route.Points, p.toPoint())
+			}
+		}
+		if len(route.Points) > 0 {
+			routes = append(routes, route)
+		}
+	}
+
+	for i, rte := range doc.Rtes {
+		route := Route{Name: gpxRouteName(rte.Name, "路线", i)}
+		for _, p := range rte.Points {
+			route.Points = append(route.Points, p.toPoint())
+		}
+		if len(route.Points) > 0 {
+			routes = append(routes, route)
+		}
+	}
+
+	if len(doc.Wpts) > 0 {
+		route := Route{Name: "航点"}
+		for _, p := range doc.Wpts {
+			route.Points = append(route.Points, p.toPoint())
+		}
+		routes = append(routes, route)
+	}
+
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("GPX 中没有轨迹、路线或航点")
+	}
+	return routes, nil
+}
+
+// WriteGPX 将路线写为 GPX 1.1 文档，每条路线对应一条 trk
+func WriteGPX(w io.Writer, routes []Route) error {
+	doc := gpxFile{
+		Xmlns:   gpxNamespace,
+		Version: "1.1",
+		Creator: "iOSGhostRun",
+		Metadata: &gpxMetadata{
+			Time: time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+	if len(routes) == 1 {
+		doc.Metadata.Name = routes[0].Name
+	}
+
+	for _, route := range routes {
+		seg := gpxSegment{Points: make([]gpxPoint, 0, len(route.Points))}
+		for _, p := range route.Points {
+			seg.Points = append(seg.Points, newGPXPoint(p))
+		}
+		doc.Trks = append(doc.Trks, gpxTrack{Name: route.Name, Segments: []gpxSegment{seg}})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("生成 GPX 失败: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func newGPXPoint(p Point) gpxPoint {
+	gp := gpxPoint{Lat: p.Lat, Lon: p.Lon, Name: p.Label}
+	if p.Ele != 0 {
+		ele := p.Ele
+		gp.Ele = &ele
+	}
+	if !p.Time.IsZero() {
+		gp.Time = p.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return gp
+}
+
+func (p gpxPoint) toPoint() Point {
+	point := Point{Lat: p.Lat, Lon: p.Lon, Label: strings.TrimSpace(p.Name)}
+	if p.Ele != nil {
+		point.Ele = *p.Ele
+	}
+	if t, ok := parseGPXTime(p.Time); ok {
+		point.Time = t
+	}
+	return point
+}
+
+// parseGPXTime 解析 GPX 时间，缺少时区时按 UTC 处理
+func parseGPXTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func gpxRouteName(name, kind string, index int) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	return fmt.Sprintf("%s %d", kind, index+1)
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGPXRoundTrip(t *testing.T) {
+	start := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	routes := []Route{
+		{Name: "晨跑", Points: []Point{
+			{Lat: 39.9, Lon: 116.39, Ele: 43.5, Time: start},
+			{Lat: 39.901, Lon: 116.391, Ele: 44, Time: start.Add(1500 * time.Millisecond)},
+			{Lat: 39.902, Lon: 116.392, Label: "折返点"},
+		}},
+		{Name: "夜跑", Points: []Point{{Lat: 31.23, Lon: 121.47}, {Lat: 31.24, Lon: 121.48}}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGPX(&buf, routes); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	got, err := ParseGPX(&buf)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if len(got) != len(routes) {
+		t.Fatalf("解析出 %d 条路线，期望 %d 条", len(got), len(routes))
+	}
+	for i, want := range routes {
+		if got[i].Name != want.Name || len(got[i].Points) != len(want.Points) {
+			t.Fatalf("路线 %d = %s（%d 个点），期望 %s（%d 个点）", i, got[i].Name, len(got[i].Points), want.Name, len(want.Points))
+		}
+		for j, p := range want.Points {
+			g := got[i].Points[j]
+			if g.Lat != p.Lat || g.Lon != p.Lon || g.Ele != p.Ele || g.Label != p.Label || !g.Time.Equal(p.Time) {
+				t.Errorf("路线 %d 点 %d = %+v，期望 %+v", i, j, g, p)
+			}
+		}
+	}
+}
+
+func TestParseGPXTrackRouteWaypoints(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <wpt lat="30.1" lon="120.1"><name> 补给站 </name></wpt>
+  <wpt lat="30.2" lon="120.2"/>
+  <rte>
+    <rtept lat="31.0" lon="121.0"/>
+    <rtept lat="31.1" lon="121.1"/>
+  </rte>
+  <trk>
+    <name>环湖</name>
+    <trkseg>
+      <trkpt lat="30.0" lon="120.0"><ele>10.5</ele><time>2024-05-01T07:00:00Z</time></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="30.01" lon="120.01"><time>2024-05-01T15:00:01+08:00</time></trkpt>
+      <trkpt lat="30.02" lon="120.02"><time>2024-05-01T07:00:02</time></trkpt>
+    </trkseg>
+  </trk>
+  <trk><trkseg/></trk>
+</gpx>`
+
+	routes, err := ParseGPX(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	// 轨迹在前、路线其次、航点最后；空轨迹跳过，多个 trkseg 依次连接
+	names := []string{"环湖", "路线 1", "航点"}
+	counts := []int{3, 2, 2}
+	if len(routes) != len(names) {
+		t.Fatalf("解析出 %d 条路线，期望 %d 条", len(routes), len(names))
+	}
+	for i, r := range routes {
+		if r.Name != names[i] || len(r.Points) != counts[i] {
+			t.Errorf("路线 %d = %s（%d 个点），期望 %s（%d 个点）", i, r.Name, len(r.Points), names[i], counts[i])
+		}
+	}
+
+	track := routes[0].Points
+	if track[0].Ele != 10.5 {
+		t.Errorf("海拔 = %v，期望 10.5", track[0].Ele)
+	}
+	// 带时区和缺少时区（按 UTC）的时间都能解析
+	for i, p := range track {
+		if want := time.Date(2024, 5, 1, 7, 0, i, 0, time.UTC); !p.Time.Equal(want) {
+			t.Errorf("轨迹点 %d 时间 = %v，期望 %v", i, p.Time, want)
+		}
+	}
+	if label := routes[2].Points[0].Label; label != "补给站" {
+		t.Errorf("航点名称 = %q，期望去除空白后的 \"补给站\"", label)
+	}
+}
+
+func TestParseGPXEmpty(t *testing.T) {
+	if _, err := ParseGPX(strings.NewReader(`<gpx version="1.1"><trk><trkseg/></trk></gpx>`)); err == nil {
+		t.Error("没有任何点的 GPX 应返回错误")
+	}
+	if _, err := ParseGPX(strings.NewReader(`<gpx`)); err == nil {
+		t.Error("格式错误的 GPX 应返回错误")
+	}
+}
@@ -8,11 +8,14 @@ import (
 	"github.com/danielpaulus/go-ios/ios"
 	"github.com/danielpaulus/go-ios/ios/amfi"
 	"github.com/danielpaulus/go-ios/ios/imagemounter"
-	"github.com/wailsapp/wails/v3/pkg/application"
 )
 
-// MountImage 挂载镜像
-func MountImage(udid string) error {
+// MountImage 挂载镜像，开发者模式菜单被显示时向 events 发送提示事件
+func MountImage(udid string, events EventSink) error {
+	if events == nil {
+		events = discardEvents{}
+	}
+
 	device, _, err := GetDeviceAndVersion(udid)
 	if err != nil {
 		return err
@@ -26,7 +29,7 @@ func MountImage(udid string) error {
 			if strings.Contains(err.Error(), "Developer Mode menu has been revealed in Settings") {
 				msg := "开发者模式菜单已显示，请在 设置 → 隐私与安全性 中启用开发者模式，然后重试"
 				Log.Info("ImageService", msg)
-				application.Get().Event.Emit("developer-mode-menu-revealed", msg)
+				events.Emit(EventDeveloperModeRevealed, msg)
 				return fmt.Errorf("%s", msg)
 			}
 			Log.Error("ImageService", fmt.Sprintf("启用开发者模式失败: %v", err))
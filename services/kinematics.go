@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// MotionProfile 加减速与过弯模型，按运动类型设置，全部为 0 时速度瞬间达到目标（与旧版本行为一致）
+type MotionProfile struct {
+	Activity       string  `json:"activity,omitempty"` // 预设名称，仅用于显示
+	Accel          float64 `json:"accel"`              // 最大加速度 m/s²
+	Decel          float64 `json:"decel"`              // 最大减速度 m/s²，提前减速以在拐角、停留点和终点前降到允许的速度
+	CornerSlowdown float64 `json:"cornerSlowdown"`     // 0-1，180° 折返时降低的速度比例，按转向角线性折算
+}
+
+const (
+	// cornerWindow 计算转向角时前后各取的距离 m，平滑后的圆弧按整个弯道计算
+	cornerWindow = 10.0
+	// minMovingSpeed 加减速过程中的最低速度 km/h，避免接近停留点时无限逼近
+	minMovingSpeed = 0.5
+)
+
+// motionPresets 各运动类型的默认加减速参数
+var motionPresets = map[string]MotionProfile{
+	"walk":  {Activity: "walk", Accel: 0.5, Decel: 1.0, CornerSlowdown: 0.3},
+	"run":   {Activity: "run", Accel: 1.0, Decel: 1.5, CornerSlowdown: 0.5},
+	"cycle": {Activity: "cycle", Accel: 0.8, Decel: 2.0, CornerSlowdown: 0.7},
+	"drive": {Activity: "drive", Accel: 2.5, Decel: 4.0, CornerSlowdown: 0.8},
+}
+
+// MotionProfilePreset 按运动类型 (none/walk/run/cycle/drive) 返回预设，不区分大小写
+func MotionProfilePreset(activity string) (MotionProfile, error) {
+	name := strings.ToLower(strings.TrimSpace(activity))
+	if name == "" || name == "none" || name == "off" {
+		return MotionProfile{}, nil
+	}
+	profile, ok := motionPresets[name]
+	if !ok {
+		return MotionProfile{}, fmt.Errorf("不支持的运动类型: %s", activity)
+	}
+	return profile, nil
+}
+
+// Validate 检查加减速参数
+func (p MotionProfile) Validate() error {
+	if !p.enabled() {
+		return nil
+	}
+	if p.Accel <= 0 || p.Decel <= 0 {
+		return fmt.Errorf("加速度和减速度必须大于 0")
+	}
+	if p.CornerSlowdown < 0 || p.CornerSlowdown > 1 {
+		return fmt.Errorf("过弯减速比例必须在 0 到 1 之间")
+	}
+	return nil
+}
+
+func (p MotionProfile) enabled() bool {
+	return p.Accel != 0 || p.Decel != 0 || p.CornerSlowdown != 0
+}
+
+// cornerFactors 每个路线点允许的速度占目标速度的比例：停留点为 0，拐角按转向角降低；
+// 终点在首尾相接的路线上按回到起点的转向计算，其余情况由 step 按是否最后一圈决定
+func cornerFactors(route []Point, slowdown float64) []float64 {
+	n := len(route)
+	proj := newLocalProjection(route)
+	xy := make([][2]float64, n)
+	for i, p := range route {
+		xy[i][0], xy[i][1] = proj.xy(p)
+	}
+	closed := n > 2 && math.Hypot(xy[0][0]-xy[n-1][0], xy[0][1]-xy[n-1][1]) < 1
+
+	// along 沿路线从 i 出发走 cornerWindow 米后到达的点，dir 为 -1 向后、1 向前
+	along := func(i, dir int) (float64, float64) {
+		j := i
+		for range n - 1 {
+			j += dir
+			if closed && j < 0 {
+				j = n - 2
+			} else if closed && j >= n {
+				j = 1
+			}
+			if j < 0 || j >= n {
+				break
+			}
+			if math.Hypot(xy[j][0]-xy[i][0], xy[j][1]-xy[i][1]) >= cornerWindow || (!closed && (j == 0 || j == n-1)) {
+				return xy[j][0], xy[j][1]
+			}
+		}
+		return xy[i][0], xy[i][1]
+	}
+
+	factors := make([]float64, n)
+	for i := range route {
+		factors[i] = 1
+		if route[i].Dwell > 0 {
+			factors[i] = 0
+			continue
+		}
+		if !closed && (i == 0 || i == n-1) {
+			continue
+		}
+		bx, by := along(i, -1)
+		ax, ay := along(i, 1)
+		inX, inY, l1 := unitVector(xy[i][0]-bx, xy[i][1]-by)
+		outX, outY, l2 := unitVector(ax-xy[i][0], ay-xy[i][1])
+		if l1 == 0 || l2 == 0 {
+			continue
+		}
+		angle := math.Acos(math.Max(-1, math.Min(1, inX*outX+inY*outY)))
+		factors[i] = 1 - slowdown*angle/math.Pi
+	}
+	return factors
+}
+
+// kinematicSpeed 按加减速限制从当前速度向当前路段的速度调整，返回本步的速度 km/h；
+// 暂停后减速到 0，否则不低于 minMovingSpeed
+func (m *motionState) kinematicSpeed(p motionParams, variation float64, dt time.Duration) float64 {
+	profile := p.profile
+	if m.corners == nil || m.cornerSlowdown != profile.CornerSlowdown {
+		m.corners = cornerFactors(m.route, profile.CornerSlowdown)
+		m.cornerSlowdown = profile.CornerSlowdown
+	}
+	want := 0.0
+	if !p.stopping {
+		want = math.Min(m.segmentSpeed(p, variation), m.brakingLimit(p, variation, dt))
+	}
+
+	v := m.velocity
+	if want > v {
+		v = math.Min(want, v+profile.Accel*3.6*dt.Seconds())
+	} else {
+		v = math.Max(want, v-profile.Decel*3.6*dt.Seconds())
+	}
+	if !p.stopping {
+		v = math.Max(v, minMovingSpeed)
+	}
+	m.velocity = v
+	return v
+}
+
+// brakingLimit 为了在前方的拐角、停留点、终点、降速的路段和目标距离前降到允许的速度，当前最多能保持的速度 km/h
+func (m *motionState) brakingLimit(p motionParams, variation float64, dt time.Duration) float64 {
+	route := m.route
+	target := m.segmentSpeed(p, variation)
+	decel := p.profile.Decel
+	// allowed 以速度 v 先走完本步 dt，再以 decel 减速，到 distance m 处恰好降到 reach km/h 时的最大 v，
+	// 即 v*dt + (v²-reach²)/(2*decel) = distance 的正根
+	step := decel * dt.Seconds()
+	allowed := func(reach, distance float64) float64 {
+		return (math.Sqrt(step*step+math.Pow(reach/3.6, 2)+2*decel*distance) - step) * 3.6
+	}
+	// 只需查看以目标速度刹停所需的距离之内的路线点
+	horizon := math.Pow(math.Max(target, m.velocity)/3.6, 2) / (2 * decel)
+	limit := math.Inf(1)
+	if p.goal.Distance > 0 {
+		// 到达目标距离时停下
+		remaining := max(p.goal.Distance-m.distanceKM, 0) * 1000
+		limit = allowed(0, remaining)
+	}
+
+	i := m.pointIndex
+	if i >= len(route)-1 {
+		return limit
+	}
+	a, b := route[i], route[i+1]
+	distance := haversine(a.Lat, a.Lon, b.Lat, b.Lon) * 1000 * (1 - m.progress)
+	for j := i + 1; j < len(route); j++ {
+		factor := m.corners[j]
+		// 到达路线点时不超过之后路段的速度，终点之后为下一圈的第一段
+		next := j
+		if j == len(route)-1 {
+			next = 0
+			if p.loopCount > 0 && m.currentLoop >= p.loopCount {
+				// 最后一圈的终点停下
+				factor = 0
+			}
+		}
+		reach := math.Min(target, m.speedFrom(p, next, variation))
+		limit = math.Min(limit, allowed(reach*factor, distance))
+		if distance > horizon || j == len(route)-1 {
+			break
+		}
+		a, b = route[j], route[j+1]
+		distance += haversine(a.Lat, a.Lon, b.Lat, b.Lon) * 1000
+	}
+	return limit
+}
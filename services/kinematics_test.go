@@ -0,0 +1,265 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// straightRoute 向北的直线路线，每段约 111 m
+func straightRoute(points int) []Point {
+	route := make([]Point, points)
+	for i := range route {
+		route[i] = Point{Lat: 30 + float64(i)*0.001, Lon: 120}
+	}
+	return route
+}
+
+// stepUntil 以 dt 为步长推进，直到 done 返回 true 或结束，返回步数
+func stepUntil(m *motionState, p motionParams, dt time.Duration, maxSteps int, done func() bool) int {
+	var since time.Duration
+	for i := range maxSteps {
+		since += dt
+		if m.step(since, dt, p) == motionFinished || done() {
+			return i + 1
+		}
+	}
+	return maxSteps
+}
+
+func TestKinematicAcceleratesFromStop(t *testing.T) {
+	profile, _ := MotionProfilePreset("run")
+	p := motionParams{speed: 12, speedScale: 1, profile: profile}
+	m := newMotionState(straightRoute(20), GPSNoiseConfig{}, 1, nil)
+
+	// 1 m/s² 从 0 加速到 12 km/h 约需 3.3 秒
+	m.step(time.Second, time.Second, p)
+	if math.Abs(m.speed-3.6) > 0.01 {
+		t.Errorf("1 秒后速度 = %.2f km/h，期望 3.6", m.speed)
+	}
+	stepUntil(m, p, 100*time.Millisecond, 30, func() bool { return false })
+	if math.Abs(m.speed-12) > 0.01 {
+		t.Errorf("4 秒后速度 = %.2f km/h，期望 12", m.speed)
+	}
+}
+
+func TestKinematicCornerCacheFollowsSlowdown(t *testing.T) {
+	route := []Point{{Lat: 30, Lon: 120}, {Lat: 30.001, Lon: 120}, {Lat: 30.001, Lon: 120.001}, {Lat: 30.002, Lon: 120.001}}
+	m := newMotionState(route, GPSNoiseConfig{}, 1, nil)
+	p := motionParams{speed: 12, speedScale: 1, profile: MotionProfile{Accel: 1, Decel: 1.5, CornerSlowdown: 0.2}}
+
+	m.step(100*time.Millisecond, 100*time.Millisecond, p)
+	want := cornerFactors(route, 0.2)
+	if m.corners[1] != want[1] {
+		t.Fatalf("拐角比例 = %.3f，期望 %.3f", m.corners[1], want[1])
+	}
+
+	p.profile.CornerSlowdown = 0.8
+	m.step(200*time.Millisecond, 100*time.Millisecond, p)
+	want = cornerFactors(route, 0.8)
+	if m.corners[1] != want[1] {
+		t.Errorf("修改过弯减速后拐角比例 = %.3f，期望 %.3f", m.corners[1], want[1])
+	}
+}
+
+func TestKinematicEnabledMidRunKeepsSpeed(t *testing.T) {
+	m := newMotionState(straightRoute(20), GPSNoiseConfig{}, 1, nil)
+	p := motionParams{speed: 12, speedScale: 1}
+	stepUntil(m, p, 100*time.Millisecond, 10, func() bool { return false })
+	if m.speed != 12 {
+		t.Fatalf("未开启加减速时速度 = %.2f km/h", m.speed)
+	}
+
+	p.profile, _ = MotionProfilePreset("run")
+	m.step(1100*time.Millisecond, 100*time.Millisecond, p)
+	if math.Abs(m.speed-12) > 0.01 {
+		t.Errorf("跑步中开启加减速后速度 = %.2f km/h，应保持 12", m.speed)
+	}
+}
+
+func TestKinematicStopsAtDistanceGoal(t *testing.T) {
+	profile, _ := MotionProfilePreset("run")
+	p := motionParams{speed: 12, speedScale: 1, profile: profile, goal: RunGoal{Distance: 0.3}}
+	m := newMotionState(straightRoute(20), GPSNoiseConfig{}, 1, nil)
+
+	var lastSpeed float64
+	stepUntil(m, p, 100*time.Millisecond, 10000, func() bool {
+		lastSpeed = m.speed
+		return false
+	})
+	if m.endReason != EndByDistance {
+		t.Fatalf("结束原因 = %v，期望按距离结束", m.endReason)
+	}
+	if math.Abs(m.distanceKM-0.3) > 1e-9 {
+		t.Errorf("结束时距离 = %f km，期望 0.3", m.distanceKM)
+	}
+	if lastSpeed > 1 {
+		t.Errorf("到达目标距离前速度 = %.2f km/h，应已减速停下", lastSpeed)
+	}
+}
+
+func TestKinematicBrakesForSlowerSegment(t *testing.T) {
+	profile, _ := MotionProfilePreset("run")
+	maxDown := profile.Decel*0.36 + 1e-9
+	const slow, fast = 6.0, 15.0
+	cases := []struct {
+		name     string
+		slowFrom int // 从该路线点起降速
+		start    int // 从该路线点满速出发
+		cross    int // 越过该路线点时应已降速
+	}{
+		{"路段中途降速", 2, 0, 2},
+		// 不是最后一圈，终点之后为下一圈降速的第一段
+		{"下一圈第一段降速", 0, 1, 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// 直线上没有拐角，只因之后路段的速度降低而减速
+			route := straightRoute(4)
+			for i := range route {
+				route[i].Speed = fast
+			}
+			route[c.slowFrom].Speed = slow
+			p := motionParams{speed: 10, speedScale: 1, profile: profile, loopCount: 2}
+			m := newMotionState(route, GPSNoiseConfig{}, 1, nil)
+			m.pointIndex, m.velocity, m.kinematic = c.start, fast, true
+
+			crossed := func() bool { return m.pointIndex >= c.cross || m.currentLoop > 1 }
+			var speeds []float64
+			stepUntil(m, p, 100*time.Millisecond, 2000, func() bool {
+				if crossed() {
+					return true
+				}
+				speeds = append(speeds, m.speed)
+				return false
+			})
+			if !crossed() || len(speeds) < 50 {
+				t.Fatalf("越过第 %d 个路线点前推进了 %d 步", c.cross, len(speeds))
+			}
+			if speeds[0] != fast {
+				t.Errorf("远离降速点时速度 = %.2f km/h，期望 %.0f", speeds[0], fast)
+			}
+			for i := 1; i < len(speeds); i++ {
+				if speeds[i-1]-speeds[i] > maxDown {
+					t.Fatalf("第 %d 步速度从 %.2f 降到 %.2f km/h，超出减速限制", i, speeds[i-1], speeds[i])
+				}
+			}
+			if last := speeds[len(speeds)-1]; last > slow+maxDown {
+				t.Errorf("越过降速点前速度 = %.2f km/h，应已降到 %.0f", last, slow)
+			}
+		})
+	}
+}
+
+func TestKinematicRunRamps(t *testing.T) {
+	profile, _ := MotionProfilePreset("run")
+	dwellRoute := squareRoute()
+	dwellRoute[2].Dwell = 10
+	// 每 100 ms 允许的速度变化 km/h
+	maxUp, maxDown := profile.Accel*0.36+1e-9, profile.Decel*0.36+1e-9
+
+	cases := []struct {
+		name  string
+		route []Point
+		pause bool
+	}{
+		{"起步、过弯和终点停下", squareRoute(), false},
+		{"停留点前减速", dwellRoute, false},
+		{"暂停时减速停下", squareRoute(), true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			run := newManualRun(t)
+			if err := run.service.SetMotionProfile(profile); err != nil {
+				t.Fatalf("设置加减速模型失败: %v", err)
+			}
+			run.start(c.route, 12)
+			if c.pause {
+				run.advance(30 * time.Second)
+				run.service.PauseRun()
+				before := len(run.events.positions())
+				run.advance(10 * time.Second)
+				// 12 km/h 以 1.5 m/s² 减速约 2.2 秒停下，之后不再下发位置
+				ramp := run.events.positions()[before:]
+				if len(ramp) < 20 || len(ramp) > 25 {
+					t.Errorf("暂停后下发了 %d 个位置，期望约 22 个", len(ramp))
+				}
+				for i := 1; i < len(ramp); i++ {
+					if ramp[i].Speed > ramp[i-1].Speed {
+						t.Fatalf("暂停后速度回升: %.2f -> %.2f km/h", ramp[i-1].Speed, ramp[i].Speed)
+					}
+				}
+				if v := ramp[len(ramp)-1].Speed; v > 0.5 {
+					t.Errorf("暂停后最后一次位置速度 = %.2f km/h，应已停下", v)
+				}
+				// 减速过程仍在移动，计入跑步时间；停下后才开始计入暂停时间
+				for i, p := range ramp {
+					if want := int64(30000 + (i+1)*100); p.ElapsedTimeMs != want {
+						t.Fatalf("暂停后第 %d 个位置的跑步时间 = %d ms，期望 %d", i, p.ElapsedTimeMs, want)
+					}
+				}
+				stopped := ramp[len(ramp)-1].ElapsedTimeMs
+				if got := run.service.GetStatus().ElapsedTimeMs; got != stopped {
+					t.Errorf("停下后跑步时间 = %d ms，期望停在 %d ms", got, stopped)
+				}
+				run.service.ResumeRun()
+				run.advance(time.Second)
+				if got := run.service.GetStatus().ElapsedTimeMs; got != stopped+1000 {
+					t.Errorf("恢复 1 秒后跑步时间 = %d ms，期望 %d", got, stopped+1000)
+				}
+			}
+			run.finish(10 * time.Minute)
+
+			positions := run.events.positions()
+			if positions[0].Speed > max(maxUp, minMovingSpeed) {
+				t.Errorf("第一步速度 = %.2f km/h，应从 0 起步", positions[0].Speed)
+			}
+			for i := 1; i < len(positions); i++ {
+				prev, cur := positions[i-1].Speed, positions[i].Speed
+				if cur == 0 && prev <= 1 {
+					// 到达停留点或暂停后停下
+					continue
+				}
+				if prev == 0 && cur <= maxUp+minMovingSpeed {
+					continue
+				}
+				if cur-prev > maxUp || prev-cur > maxDown {
+					t.Fatalf("第 %d 步速度从 %.2f 变为 %.2f km/h，超出加减速限制", i, prev, cur)
+				}
+			}
+			// 90° 拐角按 0.5 的过弯减速比例降到 9 km/h
+			cornerSpeed := math.Inf(1)
+			for _, p := range positions {
+				if p.Distance > 0.05 && p.Distance < 0.3 && p.Speed > 0 {
+					cornerSpeed = math.Min(cornerSpeed, p.Speed)
+				}
+			}
+			if cornerSpeed > 9.5 {
+				t.Errorf("过弯最低速度 = %.2f km/h，应在拐角前减速", cornerSpeed)
+			}
+			if last := positions[len(positions)-1].Speed; last > 1 {
+				t.Errorf("到达终点前速度 = %.2f km/h，应已减速停下", last)
+			}
+		})
+	}
+}
+
+func TestSetMotionProfileMidRun(t *testing.T) {
+	run := newManualRun(t)
+	run.start(straightRoute(20), 12)
+	run.advance(10 * time.Second)
+
+	profile, _ := MotionProfilePreset("walk")
+	if err := run.service.SetMotionProfile(profile); err != nil {
+		t.Fatalf("设置加减速模型失败: %v", err)
+	}
+	skip := len(run.events.positions())
+	run.advance(5 * time.Second)
+
+	// 开启后从当前速度继续，不回落到 0 重新起步
+	for _, p := range run.events.positions()[skip:] {
+		if math.Abs(p.Speed-12) > 1e-9 {
+			t.Fatalf("开启加减速后速度 = %.2f km/h，应保持 12", p.Speed)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// kmlPlacemark KML 地标，只关心名称和几何
+type kmlPlacemark struct {
+	Name          string            `xml:"name"`
+	Point         *kmlCoordinates   `xml:"Point"`
+	LineStrings   []kmlCoordinates  `xml:"LineString"`
+	Tracks        []kmlTrack        `xml:"Track"`
+	MultiTrack    *kmlMultiTrack    `xml:"MultiTrack"`
+	MultiGeometry *kmlMultiGeometry `xml:"MultiGeometry"`
+}
+
+type kmlCoordinates struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// kmlTrack gx:Track，when 与 gx:coord 一一对应
+type kmlTrack struct {
+	When   []string `xml:"when"`
+	Coords []string `xml:"coord"`
+}
+
+type kmlMultiTrack struct {
+	Tracks []kmlTrack `xml:"Track"`
+}
+
+type kmlMultiGeometry struct {
+	Points        []kmlCoordinates   `xml:"Point"`
+	LineStrings   []kmlCoordinates   `xml:"LineString"`
+	Tracks        []kmlTrack         `xml:"Track"`
+	MultiGeometry []kmlMultiGeometry `xml:"MultiGeometry"`
+}
+
+// kmlAnchorDistance 线地标 MultiGeometry 中的点离线不超过该距离（米）时作为线上的标记点
+const kmlAnchorDistance = 50.0
+
+// kmlAnchorSnap 标记点离线上已有的点不超过该距离（米）时直接标记该点，不再插入新点
+const kmlAnchorSnap = 1.0
+
+// ParseKML 解析 KML 文档
+//
+// 每个包含 LineString 或 gx:Track 的地标生成一条以地标名称命名的路线，
+// 同一地标内的多段几何依次连接，MultiGeometry 中靠近线的点（如起终点、补给点）
+// 作为带名称的标记点插入线上最近的位置；点地标和离线较远的点按文档顺序合并为一条航点路线，
+// 地标名称保存在各点的 Label 中，路线名称取自文档名称。
+func ParseKML(r io.Reader) ([]Route, error) {
+	dec := xml.NewDecoder(r)
+
+	var routes []Route
+	waypoints := Route{Name: "航点"}
+	var stack []string
+	docName := ""
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 KML 失败: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "Placemark" {
+				stack = append(stack, t.Name.Local)
+				continue
+			}
+
+			var pm kmlPlacemark
+			if err := dec.DecodeElement(&pm, &t); err != nil {
+				return nil, fmt.Errorf("解析 KML 地标失败: %w", err)
+			}
+			name := strings.TrimSpace(pm.Name)
+
+			line, err := pm.linePoints()
+			if err != nil {
+				return nil, err
+			}
+			if len(line) > 0 && name == "" {
+				name = fmt.Sprintf("路线 %d", len(routes)+1)
+			}
+
+			for _, p := range pm.pointGeometries() {
+				points, err := parseKMLCoordinates(p.Coordinates)
+				if err != nil {
+					return nil, err
+				}
+				for _, point := range points {
+					point.Label = name
+					var attached bool
+					if line, attached = attachAnchor(line, point); !attached {
+						waypoints.Points = append(waypoints.Points, point)
+					}
+				}
+			}
+			if len(line) > 0 {
+				routes = append(routes, Route{Name: name, Points: line})
+			}
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if docName == "" && len(stack) >= 2 && stack[len(stack)-1] == "name" && stack[len(stack)-2] == "Document" {
+				docName = strings.TrimSpace(string(t))
+			}
+		}
+	}
+
+	if len(waypoints.Points) > 0 {
+		if docName != "" {
+			waypoints.Name = docName
+		}
+		routes = append(routes, waypoints)
+	}
+
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("KML 中没有可用的地标")
+	}
+	return routes, nil
+}
+
+// ParseKMZ 解析 KMZ 压缩包，优先读取 doc.kml，否则读取第一个 .kml 文件
+func ParseKMZ(data []byte) ([]Route, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("解析 KMZ 失败: %w", err)
+	}
+
+	var target *zip.File
+	for _, f := range zr.File {
+		if strings.EqualFold(path.Base(f.Name), "doc.kml") {
+			target = f
+			break
+		}
+		if target == nil && strings.EqualFold(path.Ext(f.Name), ".kml") {
+			target = f
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("KMZ 中没有 KML 文件")
+	}
+
+	rc, err := target.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", target.Name, err)
+	}
+	defer rc.Close()
+
+	return ParseKML(rc)
+}
+
+// linePoints 返回地标中全部线几何的点，多段依次连接
+func (pm kmlPlacemark) linePoints() ([]Point, error) {
+	var points []Point
+	appendCoords := func(lines []kmlCoordinates) error {
+		for _, ls := range lines {
+			p, err := parseKMLCoordinates(ls.Coordinates)
+			if err != nil {
+				return err
+			}
+			points = append(points, p...)
+		}
+		return nil
+	}
+	appendTracks := func(tracks []kmlTrack) error {
+		for _, tr := range tracks {
+			p, err := tr.points()
+			if err != nil {
+				return err
+			}
+			points = append(points, p...)
+		}
+		return nil
+	}
+
+	if err := appendCoords(pm.LineStrings); err != nil {
+		return nil, err
+	}
+	if err := appendTracks(pm.Tracks); err != nil {
+		return nil, err
+	}
+	if pm.MultiTrack != nil {
+		if err := appendTracks(pm.MultiTrack.Tracks); err != nil {
+			return nil, err
+		}
+	}
+
+	var walk func(mg kmlMultiGeometry) error
+	walk = func(mg kmlMultiGeometry) error {
+		if err := appendCoords(mg.LineStrings); err != nil {
+			return err
+		}
+		if err := appendTracks(mg.Tracks); err != nil {
+			return err
+		}
+		for _, child := range mg.MultiGeometry {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if pm.MultiGeometry != nil {
+		if err := walk(*pm.MultiGeometry); err != nil {
+			return nil, err
+		}
+	}
+	return points, nil
+}
+
+// pointGeometries 返回地标中全部点几何
+func (pm kmlPlacemark) pointGeometries() []kmlCoordinates {
+	var points []kmlCoordinates
+	if pm.Point != nil {
+		points = append(points, *pm.Point)
+	}
+
+	var walk func(mg kmlMultiGeometry)
+	walk = func(mg kmlMultiGeometry) {
+		points = append(points, mg.Points...)
+		for _, child := range mg.MultiGeometry {
+			walk(child)
+		}
+	}
+	if pm.MultiGeometry != nil {
+		walk(*pm.MultiGeometry)
+	}
+	return points
+}
+
+// attachAnchor 将带名称的点作为标记点放到线上最近的位置：离已有的点很近时标记该点，
+// 否则在最近的线段上插入垂足；点离线太远或最近的点已有名称时返回 false
+func attachAnchor(line []Point, anchor Point) ([]Point, bool) {
+	if len(line) < 2 {
+		return line, false
+	}
+
+	proj := newLocalProjection(line)
+	px, py := proj.xy(anchor)
+	best, bestT, bestDist := 0, 0.0, math.Inf(1)
+	for i := 0; i+1 < len(line); i++ {
+		ax, ay := proj.xy(line[i])
+		bx, by := proj.xy(line[i+1])
+		t := 0.0
+		if dx, dy := bx-ax, by-ay; dx != 0 || dy != 0 {
+			t = math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/(dx*dx+dy*dy)))
+		}
+		if d := math.Hypot(px-(ax+t*(bx-ax)), py-(ay+t*(by-ay))); d < bestDist {
+			best, bestT, bestDist = i, t, d
+		}
+	}
+	if bestDist > kmlAnchorDistance {
+		return line, false
+	}
+
+	foot := interpolatePoint(line[best], line[best+1], bestT)
+	for _, i := range []int{best, best + 1} {
+		if haversine(foot.Lat, foot.Lon, line[i].Lat, line[i].Lon)*1000 > kmlAnchorSnap {
+			continue
+		}
+		if line[i].Label != "" {
+			return line, false
+		}
+		line[i].Label = anchor.Label
+		return line, true
+	}
+	foot.Label = anchor.Label
+	return slices.Insert(line, best+1, foot), true
+}
+
+// points 解析 gx:Track 的坐标和时间
+func (tr kmlTrack) points() ([]Point, error) {
+	points := make([]Point, 0, len(tr.Coords))
+	for i, c := range tr.Coords {
+		// gx:coord 以空格分隔：经度 纬度 [海拔]
+		p, err := parseKMLTuple(strings.Fields(c))
+		if err != nil {
+			return nil, err
+		}
+		if i < len(tr.When) {
+			if t, ok := parseGPXTime(tr.When[i]); ok {
+				p.Time = t
+			}
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// kmlCommaSpace 逗号两侧的空白，部分工具导出为 "经度, 纬度"
+var kmlCommaSpace = regexp.MustCompile(`\s*,\s*`)
+
+// parseKMLCoordinates 解析 coordinates 文本：以空白分隔的"经度,纬度[,海拔]"，
+// 逗号两侧的空白先去掉，避免同一个坐标被拆成两组
+func parseKMLCoordinates(s string) ([]Point, error) {
+	var points []Point
+	for _, tuple := range strings.Fields(kmlCommaSpace.ReplaceAllString(s, ",")) {
+		p, err := parseKMLTuple(strings.Split(tuple, ","))
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+func parseKMLTuple(fields []string) (Point, error) {
+	if len(fields) < 2 {
+		return Point{}, fmt.Errorf("无效的 KML 坐标: %q", strings.Join(fields, ","))
+	}
+	lon, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("无效的 KML 经度: %q", fields[0])
+	}
+	lat, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("无效的 KML 纬度: %q", fields[1])
+	}
+
+	p := Point{Lat: lat, Lon: lon}
+	if len(fields) >= 3 {
+		if ele, err := strconv.ParseFloat(fields[2], 64); err == nil {
+			p.Ele = ele
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseKMLCoordinatesSpacing(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+	}{
+		{"紧凑", "116.39,39.90,50 116.40,39.91"},
+		{"逗号后空格", "116.39, 39.90, 50 116.40, 39.91"},
+		{"逗号前后空格", "116.39 , 39.90 ,50\n\t116.40 ,\t39.91"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			points, err := parseKMLCoordinates(c.text)
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if len(points) != 2 {
+				t.Fatalf("点数 = %d，期望 2", len(points))
+			}
+			if points[0].Lon != 116.39 || points[0].Lat != 39.90 || points[0].Ele != 50 {
+				t.Errorf("第一个点 = %+v", points[0])
+			}
+			if points[1].Lon != 116.40 || points[1].Lat != 39.91 {
+				t.Errorf("第二个点 = %+v", points[1])
+			}
+		})
+	}
+}
+
+func TestParseKMLMultiGeometryAnchors(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+<Document>
+  <name>周末路线</name>
+  <Placemark>
+    <name>环线</name>
+    <MultiGeometry>
+      <LineString><coordinates>120.000,30.000 120.010,30.000 120.010,30.010</coordinates></LineString>
+      <Point><coordinates>120.005,30.0001</coordinates></Point>
+      <Point><coordinates>120.010,30.000</coordinates></Point>
+      <Point><coordinates>120.100,30.100</coordinates></Point>
+    </MultiGeometry>
+  </Placemark>
+  <Placemark>
+    <name>集合点</name>
+    <Point><coordinates>120.2,30.2</coordinates></Point>
+  </Placemark>
+</Document>
+</kml>`
+
+	routes, err := ParseKML(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if len(routes) != 2 || routes[0].Name != "环线" || routes[1].Name != "周末路线" {
+		t.Fatalf("解析结果 = %+v", routes)
+	}
+
+	// 线附近的点插入到最近线段的垂足，与已有点重合的点直接标记该点
+	line := routes[0].Points
+	want := []Point{
+		{Lat: 30, Lon: 120},
+		{Lat: 30, Lon: 120.005, Label: "环线"},
+		{Lat: 30, Lon: 120.01, Label: "环线"},
+		{Lat: 30.01, Lon: 120.01},
+	}
+	if len(line) != len(want) {
+		t.Fatalf("线上 %d 个点，期望 %d 个: %+v", len(line), len(want), line)
+	}
+	for i, p := range line {
+		if math.Abs(p.Lat-want[i].Lat) > 1e-9 || math.Abs(p.Lon-want[i].Lon) > 1e-9 || p.Label != want[i].Label {
+			t.Errorf("线上第 %d 个点 = %+v，期望 %+v", i, p, want[i])
+		}
+	}
+
+	// 离线较远的点与点地标一起归入航点路线
+	waypoints := routes[1].Points
+	if len(waypoints) != 2 || waypoints[0].Label != "环线" || waypoints[1].Label != "集合点" {
+		t.Errorf("航点 = %+v", waypoints)
+	}
+}
+
+func TestAttachAnchorLabelledVertex(t *testing.T) {
+	line := []Point{{Lat: 30, Lon: 120, Label: "起点"}, {Lat: 30, Lon: 120.01}}
+	if _, ok := attachAnchor(line, Point{Lat: 30, Lon: 120, Label: "集合"}); ok {
+		t.Error("已有名称的点不应被覆盖")
+	}
+	if _, ok := attachAnchor(line[:1], Point{Lat: 30, Lon: 120, Label: "集合"}); ok {
+		t.Error("只有一个点的线不应附加标记点")
+	}
+}
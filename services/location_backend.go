@@ -0,0 +1,182 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/danielpaulus/go-ios/ios"
+	"github.com/danielpaulus/go-ios/ios/instruments"
+	"github.com/danielpaulus/go-ios/ios/simlocation"
+)
+
+// LocationBackend 位置模拟后端
+//
+// RunningService 只通过该接口下发位置，真实设备、录制后端或远程后端都可以接入。
+type LocationBackend interface {
+	// SetLocation 将设备位置设置为指定的 WGS84 坐标
+	SetLocation(udid string, lat, lon float64) error
+	// ResetLocation 恢复设备真实位置
+	ResetLocation(udid string) error
+	// Close 释放后端持有的全部会话
+	Close() error
+}
+
+// simLocationBackend iOS 17 以下设备，通过 simlocation 服务设置位置
+type simLocationBackend struct{}
+
+func (simLocationBackend) SetLocation(udid string, lat, lon float64) error {
+	device, err := ios.GetDevice(udid)
+	if err != nil {
+		return fmt.Errorf("获取设备失败: %w", err)
+	}
+
+	err = simlocation.SetLocation(device, fmt.Sprintf("%f", lat), fmt.Sprintf("%f", lon))
+	if err != nil {
+		Log.Error("LocationService", fmt.Sprintf("设置位置失败 for %s: %v", udid, err))
+		return fmt.Errorf("设置位置失败: %w", err)
+	}
+	return nil
+}
+
+func (simLocationBackend) ResetLocation(udid string) error {
+	device, err := ios.GetDevice(udid)
+	if err != nil {
+		return fmt.Errorf("获取设备失败: %w", err)
+	}
+
+	if err := simlocation.ResetLocation(device); err != nil {
+		Log.Error("LocationService", fmt.Sprintf("重置位置失败 for %s: %v", udid, err))
+		return fmt.Errorf("重置位置失败: %w", err)
+	}
+	return nil
+}
+
+func (simLocationBackend) Close() error {
+	return nil
+}
+
+// instrumentsLocationBackend iOS 17+ 设备，通过 tunnel 连接 dtservicehub 的位置模拟服务
+type instrumentsLocationBackend struct {
+	mu      sync.Mutex
+	servers map[string]*instruments.LocationSimulationService
+}
+
+func newInstrumentsLocationBackend() *instrumentsLocationBackend {
+	return &instrumentsLocationBackend{
+		servers: make(map[string]*instruments.LocationSimulationService),
+	}
+}
+
+func (b *instrumentsLocationBackend) SetLocation(udid string, lat, lon float64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 检查是否已经有服务实例，没有才创建
+	server, exists := b.servers[udid]
+	if !exists {
+		device, err := getTunnelDevice(udid)
+		if err != nil {
+			return fmt.Errorf("获取隧道设备失败: %w", err)
+		}
+
+		server, err = instruments.NewLocationSimulationService(*device)
+		if err != nil {
+			return fmt.Errorf("创建位置模拟服务失败: %w", err)
+		}
+		// 保存服务实例供后续复用
+		b.servers[udid] = server
+	}
+
+	// 使用已存在的服务多次定位
+	if err := server.StartSimulateLocation(lat, lon); err != nil {
+		return fmt.Errorf("启动位置模拟失败: %w", err)
+	}
+	return nil
+}
+
+func (b *instrumentsLocationBackend) ResetLocation(udid string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if server, exists := b.servers[udid]; exists {
+		if err := server.StopSimulateLocation(); err != nil {
+			Log.Error("LocationService", fmt.Sprintf("停止位置模拟失败 for %s: %v", udid, err))
+		}
+		delete(b.servers, udid)
+	}
+	return nil
+}
+
+func (b *instrumentsLocationBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for udid, server := range b.servers {
+		if err := server.StopSimulateLocation(); err != nil {
+			Log.Error("LocationService", fmt.Sprintf("停止位置模拟失败 for %s: %v", udid, err))
+		}
+		delete(b.servers, udid)
+	}
+	return nil
+}
+
+// LocationRecord 录制后端记录的一次位置下发
+type LocationRecord struct {
+	UDID  string    `json:"udid"`
+	Lat   float64   `json:"lat"`
+	Lon   float64   `json:"lon"`
+	Reset bool      `json:"reset"`
+	Time  time.Time `json:"time"`
+}
+
+// MemoryLocationBackend 内存录制后端，不连接设备，用于测试和演示
+type MemoryLocationBackend struct {
+	mu      sync.Mutex
+	records []LocationRecord
+	current map[string]Point
+}
+
+// NewMemoryLocationBackend 创建内存录制后端
+func NewMemoryLocationBackend() *MemoryLocationBackend {
+	return &MemoryLocationBackend{
+		current: make(map[string]Point),
+	}
+}
+
+func (m *MemoryLocationBackend) SetLocation(udid string, lat, lon float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, LocationRecord{UDID: udid, Lat: lat, Lon: lon, Time: time.Now()})
+	m.current[udid] = Point{Lat: lat, Lon: lon}
+	return nil
+}
+
+func (m *MemoryLocationBackend) ResetLocation(udid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, LocationRecord{UDID: udid, Reset: true, Time: time.Now()})
+	delete(m.current, udid)
+	return nil
+}
+
+func (m *MemoryLocationBackend) Close() error {
+	return nil
+}
+
+// Records 返回已录制的全部位置下发
+func (m *MemoryLocationBackend) Records() []LocationRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]LocationRecord(nil), m.records...)
+}
+
+// Current 返回设备当前被模拟的位置，未模拟时 ok 为 false
+func (m *MemoryLocationBackend) Current(udid string) (Point, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.current[udid]
+	return p, ok
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryLocationBackend(t *testing.T) {
+	m := NewMemoryLocationBackend()
+	if _, ok := m.Current("a"); ok {
+		t.Fatal("未下发位置时不应有当前位置")
+	}
+
+	_ = m.SetLocation("a", 30, 120)
+	_ = m.SetLocation("b", 31, 121)
+	_ = m.SetLocation("a", 30.5, 120.5)
+	if p, ok := m.Current("a"); !ok || p.Lat != 30.5 || p.Lon != 120.5 {
+		t.Errorf("设备 a 当前位置 = %+v, %v，期望最后一次下发的 (30.5, 120.5)", p, ok)
+	}
+
+	_ = m.ResetLocation("a")
+	if _, ok := m.Current("a"); ok {
+		t.Error("重置后设备 a 不应有当前位置")
+	}
+	if _, ok := m.Current("b"); !ok {
+		t.Error("重置设备 a 不应影响设备 b")
+	}
+
+	records := m.Records()
+	want := []LocationRecord{
+		{UDID: "a", Lat: 30, Lon: 120},
+		{UDID: "b", Lat: 31, Lon: 121},
+		{UDID: "a", Lat: 30.5, Lon: 120.5},
+		{UDID: "a", Reset: true},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("记录 %d 条，期望 %d 条", len(records), len(want))
+	}
+	for i, r := range records {
+		if r.Time.IsZero() {
+			t.Errorf("第 %d 条记录缺少时间", i)
+		}
+		r.Time = time.Time{}
+		if r != want[i] {
+			t.Errorf("第 %d 条记录 = %+v，期望 %+v", i, r, want[i])
+		}
+	}
+
+	// 返回的是副本，修改不影响后端
+	records[0].Lat = 0
+	if m.Records()[0].Lat != 30 {
+		t.Error("修改 Records 的返回值影响了后端")
+	}
+}
+
+func TestRunningServiceWithMemoryBackend(t *testing.T) {
+	run := newManualRun(t)
+	run.start(squareRoute(), 12)
+	run.advance(10 * time.Second)
+
+	current, ok := run.location.Current("test-device")
+	if !ok {
+		t.Fatal("跑步中设备没有当前位置")
+	}
+	status := run.service.GetStatus()
+	if d := haversine(current.Lat, current.Lon, status.CurrentLat, status.CurrentLon); d > 0.001 {
+		t.Errorf("后端当前位置与跑步状态相差 %.1f m", d*1000)
+	}
+
+	// 停止跑步时恢复真实位置
+	run.service.StopRun()
+	if _, ok := run.location.Current("test-device"); ok {
+		t.Error("停止跑步后设备仍有模拟位置")
+	}
+	records := run.location.Records()
+	if len(records) != 101 || !records[len(records)-1].Reset {
+		t.Errorf("记录 %d 条，期望 100 次下发加 1 次重置", len(records))
+	}
+}
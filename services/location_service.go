@@ -3,99 +3,81 @@ package services
 import (
 	"fmt"
 	"sync"
-
-	"github.com/danielpaulus/go-ios/ios"
-
-	"github.com/danielpaulus/go-ios/ios/instruments"
-	"github.com/danielpaulus/go-ios/ios/simlocation"
 )
 
 // LocationService 位置模拟服务
+//
+// 默认按设备系统版本选择后端：iOS 17 以下使用 simlocation，iOS 17+ 使用 instruments。
+// 通过 NewLocationServiceWithBackend 可以固定使用指定后端。
 type LocationService struct {
-	mu              sync.Mutex
-	locationServers map[string]*instruments.LocationSimulationService
+	mu          sync.Mutex
+	backend     LocationBackend
+	legacy      LocationBackend
+	instruments LocationBackend
 }
 
 func NewLocationService() *LocationService {
 	return &LocationService{
-		locationServers: make(map[string]*instruments.LocationSimulationService),
+		legacy:      simLocationBackend{},
+		instruments: newInstrumentsLocationBackend(),
 	}
 }
 
-func (l *LocationService) SetLocation(udid string, lat, lon float64) error {
+// NewLocationServiceWithBackend 创建固定使用指定后端的位置服务
+func NewLocationServiceWithBackend(backend LocationBackend) *LocationService {
+	return &LocationService{backend: backend}
+}
+
+// backendFor 选择设备对应的位置后端
+func (l *LocationService) backendFor(udid string) LocationBackend {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if l.locationServers == nil {
-		l.locationServers = make(map[string]*instruments.LocationSimulationService)
+	if l.backend != nil {
+		return l.backend
 	}
-
 	// iOS 17+ 需要通过 tunnel 设备对象连接 dtservicehub
 	if IsVersionAbove17(udid) {
-		device, err := getTunnelDevice(udid)
-		if err != nil {
-			return fmt.Errorf("获取隧道设备失败: %w", err)
-		}
-
-		// 检查是否已经有服务实例，没有才创建
-		server, exists := l.locationServers[udid]
-		if !exists {
-			var err error
-			server, err = instruments.NewLocationSimulationService(*device)
-			if err != nil {
-				return fmt.Errorf("创建位置模拟服务失败: %w", err)
-			}
-			// 保存服务实例供后续复用
-			l.locationServers[udid] = server
+		if l.instruments == nil {
+			l.instruments = newInstrumentsLocationBackend()
 		}
-
-		// 使用已存在的服务多次定位
-		if err := server.StartSimulateLocation(lat, lon); err != nil {
-			return fmt.Errorf("启动位置模拟失败: %w", err)
-		}
-
-		return nil
+		return l.instruments
 	}
-
-	device, err := ios.GetDevice(udid)
-	if err != nil {
-		return fmt.Errorf("获取设备失败: %w", err)
-	}
-
-	err = simlocation.SetLocation(device, fmt.Sprintf("%f", lat), fmt.Sprintf("%f", lon))
-	if err != nil {
-		Log.Error("LocationService", fmt.Sprintf("设置位置失败 for %s: %v", udid, err))
-		return fmt.Errorf("设置位置失败: %w", err)
+	if l.legacy == nil {
+		l.legacy = simLocationBackend{}
 	}
+	return l.legacy
+}
 
-	return nil
+func (l *LocationService) SetLocation(udid string, lat, lon float64) error {
+	return l.backendFor(udid).SetLocation(udid, lat, lon)
 }
 
 // ResetLocation 重置设备位置
 func (l *LocationService) ResetLocation(udid string) error {
 	Log.Info("LocationService", fmt.Sprintf("重置设备 %s 位置...", udid))
+
+	if err := l.backendFor(udid).ResetLocation(udid); err != nil {
+		return err
+	}
+
+	Log.Info("LocationService", fmt.Sprintf("设备 %s 位置已重置", udid))
+	return nil
+}
+
+// Close 释放所有位置模拟会话
+func (l *LocationService) Close() error {
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	backends := []LocationBackend{l.backend, l.legacy, l.instruments}
+	l.mu.Unlock()
 
-	if IsVersionAbove17(udid) {
-		if server, exists := l.locationServers[udid]; exists {
-			if err := server.StopSimulateLocation(); err != nil {
-				Log.Error("LocationService", fmt.Sprintf("停止位置模拟失败 for %s: %v", udid, err))
-			}
-			delete(l.locationServers, udid)
+	for _, backend := range backends {
+		if backend == nil {
+			continue
 		}
-	} else {
-		device, err := ios.GetDevice(udid)
-		if err != nil {
-			return fmt.Errorf("获取设备失败: %w", err)
-		}
-
-		if err := simlocation.ResetLocation(device); err != nil {
-			Log.Error("LocationService", fmt.Sprintf("重置位置失败 for %s: %v", udid, err))
-			return fmt.Errorf("重置位置失败: %w", err)
+		if err := backend.Close(); err != nil {
+			return err
 		}
 	}
-
-	Log.Info("LocationService", fmt.Sprintf("设备 %s 位置已重置", udid))
 	return nil
 }
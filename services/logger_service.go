@@ -4,8 +4,6 @@ import (
 	"log/slog"
 	"sync/atomic"
 	"time"
-
-	"github.com/wailsapp/wails/v3/pkg/application"
 )
 
 type LogEntry struct {
@@ -19,12 +17,18 @@ var Log *LoggerService
 var appShuttingDown atomic.Bool
 
 type LoggerService struct {
-	logs []LogEntry
+	logs   []LogEntry
+	events EventSink
 }
 
-func NewLoggerService() *LoggerService {
+// NewLoggerService 创建日志服务，每条日志会以 log-event 事件发送到 events
+func NewLoggerService(events EventSink) *LoggerService {
+	if events == nil {
+		events = discardEvents{}
+	}
 	Log = &LoggerService{
-		logs: make([]LogEntry, 0),
+		logs:   make([]LogEntry, 0),
+		events: events,
 	}
 	return Log
 }
@@ -60,7 +64,7 @@ func (l *LoggerService) logMessage(level string, module string, message string)
 
 	// 退出阶段不再向前端分发日志事件，避免关闭流程阻塞。
 	if !appShuttingDown.Load() {
-		application.Get().Event.Emit("log-event", entry)
+		l.events.Emit(EventLog, entry)
 	}
 }
 
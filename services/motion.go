@@ -0,0 +1,290 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// motionParams 运动模型每一步读取的参数，跑步中可随时调整
+type motionParams struct {
+	speed         float64 // 未指定速度的路段使用的速度 km/h
+	speedScale    float64 // 路线点自带速度的倍率
+	speedVariance float64 // km/h
+	routeOffset   float64 // m
+	offsetBias    float64 // m
+	loopCount     int
+	targetFinish  time.Duration // 目标完成时间，从开始跑步算起，含暂停；0 表示按速度跑
+	goal          RunGoal
+	profile       MotionProfile
+	stopping      bool // 已暂停，按减速度减速到停下
+}
+
+// motionStatus 运动模型一步之后的状态
+type motionStatus int
+
+const (
+	motionMoving   motionStatus = iota // 已推进，lat、lon 为应下发的位置
+	motionHolding                      // 停在终点，等待停留结束或下一步完成
+	motionFinished                     // 已结束，原因见 endReason
+)
+
+// motionState 跑步运动模型，runLoop 与 Simulate 共用，保证预览与实际下发的轨迹一致
+type motionState struct {
+	route          []Point
+	pointIndex     int
+	progress       float64 // 当前段内的进度 0-1
+	currentLoop    int
+	distanceKM     float64
+	elapsed        time.Duration // 跑步时间，含停留，不含暂停
+	dwellRemaining time.Duration // 在路线点停留的剩余时间
+	endReason      RunEndReason
+
+	wander  *wanderNoise
+	noise   noiseStack
+	workout *workoutProgress // 间歇训练进度，nil 表示未设置训练
+	pace    *pacePlan        // 目标完成时间模式的计划配速，首次使用时按当时的速度生成
+	factor  float64          // 本步的速度倍数，目标完成时间模式之外为 1
+
+	velocity       float64   // 加减速模型下的实际速度 km/h
+	kinematic      bool      // 上一步是否使用加减速模型，跑步中开启时从当前速度起步
+	corners        []float64 // 各路线点允许的速度比例，过弯减速比例变化时重新计算
+	cornerSlowdown float64   // corners 对应的过弯减速比例
+
+	// 最近一次 motionMoving 的输出
+	lat, lon float64
+	speed    float64 // km/h
+}
+
+// newMotionState 创建运动模型，随机量全部来自 seed，workout 为 nil 时按速度和圈数跑
+func newMotionState(route []Point, noise GPSNoiseConfig, seed int64, workout *Workout) *motionState {
+	rng := rand.New(rand.NewSource(seed))
+	return &motionState{
+		route:       route,
+		currentLoop: 1,
+		wander:      &wanderNoise{rng: rng},
+		noise:       newNoiseStack(noise, rng),
+		workout:     newWorkoutProgress(workout),
+	}
+}
+
+// position 当前在路线上的位置，不含偏移和噪声
+func (m *motionState) position() (float64, float64) {
+	if m.pointIndex >= len(m.route)-1 {
+		end := m.route[len(m.route)-1]
+		return end.Lat, end.Lon
+	}
+	a, b := m.route[m.pointIndex], m.route[m.pointIndex+1]
+	return a.Lat + (b.Lat-a.Lat)*m.progress, a.Lon + (b.Lon-a.Lon)*m.progress
+}
+
+// interval 当前训练阶段，未设置训练或训练已结束时返回 nil
+func (m *motionState) interval() *IntervalStatus {
+	return m.workout.current()
+}
+
+// step 推进 dt，sinceStart 为开始跑步至今的时间，用于速度波动
+func (m *motionState) step(sinceStart, dt time.Duration, p motionParams) motionStatus {
+	route := m.route
+	if m.workout != nil {
+		// 训练按阶段结束，路线跑完后继续循环
+		p.loopCount = 0
+	}
+	if p.goal.Duration > 0 {
+		// 达到目标时间的一步只推进到目标时刻
+		dt = min(dt, max(p.goal.duration()-m.elapsed, 0))
+	}
+	m.elapsed += dt
+
+	// 检查是否完成
+	if m.pointIndex >= len(route)-1 {
+		if m.dwellRemaining > 0 {
+			// 在终点停留结束后再完成
+			m.dwellRemaining -= dt
+			m.workout.advance(dt, m.distanceKM)
+			if m.finished(p) {
+				return motionFinished
+			}
+			return motionHolding
+		}
+		if p.loopCount > 0 && m.currentLoop >= p.loopCount {
+			m.pointIndex = len(route) - 1
+			m.progress = 1
+			m.endReason = EndByLoops
+			return motionFinished
+		}
+		// 开始新的循环
+		m.pointIndex = 0
+		m.progress = 0
+		m.currentLoop++
+	}
+
+	m.factor = 1
+	if p.targetFinish > 0 && (p.loopCount > 0 || p.goal.Distance > 0) {
+		if m.pace == nil {
+			m.pace = newPacePlan(route, p.speed)
+		}
+		m.factor = m.pace.factor(m, p, p.targetFinish-sinceStart)
+	}
+
+	var variation float64
+	if p.speedVariance > 0 {
+		// 使用正弦函数实现速度的平滑波动
+		variation = math.Sin(sinceStart.Seconds()*0.5) * p.speedVariance * 0.5
+	}
+	// 按真实经过时间推进，避免设备位置注入耗时导致实际速度偏慢。
+	// 停留期间不移动，停留结束后剩余的时间继续用于移动
+	moveDuration := dt
+	if m.dwellRemaining > 0 {
+		m.dwellRemaining -= dt
+		moveDuration = max(-m.dwellRemaining, 0)
+		m.dwellRemaining = max(m.dwellRemaining, 0)
+	}
+	remainingMS := float64(moveDuration.Milliseconds())
+
+	// 设置了加减速模型时，本步按限制加减速后的速度移动；停留期间速度为 0
+	kinematic := p.profile.enabled()
+	if kinematic && !m.kinematic {
+		// 跑步中开启加减速模型时从上一步的速度开始调整，而不是从 0 重新起步
+		m.velocity = m.speed
+	}
+	m.kinematic = kinematic
+	var velocity float64
+	if kinematic && moveDuration > 0 {
+		velocity = m.kinematicSpeed(p, variation, moveDuration)
+	}
+
+	// 按距离推进进度，避免重复累计导致距离异常；跨过路线点后按新路段的速度继续
+	for remainingMS > 0 {
+		if m.pointIndex >= len(route)-1 {
+			break
+		}
+
+		startPoint := route[m.pointIndex]
+		endPoint := route[m.pointIndex+1]
+		segmentDistanceKM := haversine(startPoint.Lat, startPoint.Lon, endPoint.Lat, endPoint.Lon)
+
+		if segmentDistanceKM < 0.0001 {
+			m.pointIndex++
+			m.progress = 0
+			continue
+		}
+
+		speed := velocity
+		if !kinematic {
+			speed = m.segmentSpeed(p, variation)
+		}
+		if speed <= 0 {
+			break
+		}
+		speedKMMS := speed / (3600 * 1000)
+		remainingMoveKM := speedKMMS * remainingMS
+		remainingInSegmentKM := segmentDistanceKM * (1 - m.progress)
+		if p.goal.Distance > 0 {
+			// 目标距离落在本段内时恰好停在目标处
+			if goalKM := p.goal.Distance - m.distanceKM; goalKM <= remainingInSegmentKM && remainingMoveKM >= goalKM {
+				m.progress += max(goalKM, 0) / segmentDistanceKM
+				m.distanceKM = max(m.distanceKM, p.goal.Distance)
+				break
+			}
+		}
+		if remainingMoveKM < remainingInSegmentKM {
+			m.progress += remainingMoveKM / segmentDistanceKM
+			m.distanceKM += remainingMoveKM
+			remainingMS = 0
+			continue
+		}
+
+		m.distanceKM += remainingInSegmentKM
+		remainingMS -= remainingInSegmentKM / speedKMMS
+		m.pointIndex++
+		m.progress = 0
+
+		// 到达需要停留的路线点，本次剩余的移动时间作废
+		if dwell := route[m.pointIndex].Dwell; dwell > 0 {
+			m.dwellRemaining = time.Duration(dwell * float64(time.Second))
+			m.velocity = 0
+			remainingMS = 0
+		}
+
+		if m.pointIndex >= len(route)-1 {
+			if p.loopCount > 0 && m.currentLoop >= p.loopCount {
+				break
+			}
+			m.pointIndex = 0
+			m.currentLoop++
+		}
+	}
+
+	m.workout.advance(dt, m.distanceKM)
+	if m.finished(p) {
+		return motionFinished
+	}
+
+	// 检查是否到达终点
+	if m.pointIndex >= len(route)-1 {
+		return motionHolding
+	}
+	currentSpeed := m.segmentSpeed(p, variation)
+	if kinematic {
+		currentSpeed = m.velocity
+	}
+	if m.dwellRemaining > 0 {
+		currentSpeed = 0
+	}
+
+	startPoint := route[m.pointIndex]
+	endPoint := route[m.pointIndex+1]
+
+	// 在两点之间进行线性插值计算当前位置
+	lat := startPoint.Lat + (endPoint.Lat-startPoint.Lat)*m.progress
+	lon := startPoint.Lon + (endPoint.Lon-startPoint.Lon)*m.progress
+
+	// 路线偏移与 GPS 噪声：以当前路段方向为参照，横向偏移垂直于前进方向
+	m.wander.amplitude = p.routeOffset
+	_, lateral := m.wander.Next(dt)
+	along, right := m.noise.Next(dt)
+	m.lat, m.lon = offsetLocal(lat, lon, startPoint, endPoint, along, right+lateral+p.offsetBias)
+	m.speed = currentSpeed
+	return motionMoving
+}
+
+// finished 检查训练和结束目标，达到时记录结束原因
+func (m *motionState) finished(p motionParams) bool {
+	switch {
+	case m.workout.finished():
+		m.endReason = EndByWorkout
+	case p.goal.Distance > 0 && m.distanceKM >= p.goal.Distance:
+		m.endReason = EndByDistance
+	case p.goal.Duration > 0 && m.elapsed >= p.goal.duration():
+		m.endReason = EndByDuration
+	default:
+		return false
+	}
+	return true
+}
+
+// segmentSpeed 当前路段的速度 km/h，再叠加波动：
+// 训练中使用当前阶段的速度，路段起点自带速度时使用该速度，二者都按倍率缩放；否则使用全局速度。
+// 目标完成时间模式下改为按计划配速乘以本步的速度倍数
+func (m *motionState) segmentSpeed(p motionParams, variation float64) float64 {
+	return m.speedFrom(p, m.pointIndex, variation)
+}
+
+// speedFrom 从第 index 个路线点开始的路段的速度 km/h，规则同 segmentSpeed
+func (m *motionState) speedFrom(p motionParams, index int, variation float64) float64 {
+	speed := p.speed
+	scale := p.speedScale
+	if m.pace != nil {
+		scale = m.factor
+		speed = m.pace.nominal * scale
+	}
+	if phase := m.interval(); phase != nil {
+		speed = phase.Speed * scale
+	} else if index < len(m.route) {
+		if s := m.route[index].Speed; s > 0 {
+			speed = s * scale
+		}
+	}
+	return max(speed+variation, 0.5)
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// pacedRoute 正方形路线，第三段未指定速度，按全局速度跑
+func pacedRoute() []Point {
+	route := squareRoute()
+	route[0].Speed = 6
+	route[1].Speed = 12
+	route[3].Speed = 9
+	return route
+}
+
+func TestPerPointPace(t *testing.T) {
+	route := pacedRoute()
+	run := newManualRun(t)
+	run.start(route, 8)
+	status, last := run.finish(10 * time.Minute)
+
+	want := map[int]float64{0: 6, 1: 12, 2: 8, 3: 9}
+	for _, p := range run.events.positions() {
+		if p.CurrentIndex >= len(route)-1 {
+			continue
+		}
+		if p.Speed != want[p.CurrentIndex] {
+			t.Fatalf("路段 %d 的速度 = %.2f km/h，期望 %.2f", p.CurrentIndex, p.Speed, want[p.CurrentIndex])
+		}
+	}
+
+	// 总时间为各路段按各自速度所需时间之和
+	var hours float64
+	for i := 0; i < len(route)-1; i++ {
+		hours += haversine(route[i].Lat, route[i].Lon, route[i+1].Lat, route[i+1].Lon) / want[i]
+	}
+	expected := time.Duration(hours * float64(time.Hour))
+	if d := time.Duration(last.ElapsedTimeMs)*time.Millisecond - expected; d < -200*time.Millisecond || d > 0 {
+		t.Errorf("完成前最后一次位置的跑步时间 = %d ms，期望略早于 %s", last.ElapsedTimeMs, expected)
+	}
+	if status.CompletedBy != EndByLoops {
+		t.Errorf("结束原因 = %q", status.CompletedBy)
+	}
+}
+
+func TestPerPointPaceScaledBySetSpeed(t *testing.T) {
+	cases := []struct {
+		name  string
+		speed float64
+		want  map[int]float64
+	}{
+		{"加速一倍", 16, map[int]float64{0: 12, 1: 24, 2: 16, 3: 18}},
+		{"减速一半", 4, map[int]float64{0: 3, 1: 6, 2: 4, 3: 4.5}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			run := newManualRun(t)
+			run.start(pacedRoute(), 8)
+			run.advance(5 * time.Second)
+			run.service.SetSpeed(c.speed)
+			skip := len(run.events.positions())
+			run.finish(20 * time.Minute)
+
+			positions := run.events.positions()
+			for _, p := range positions[skip:] {
+				if p.CurrentIndex >= len(pacedRoute())-1 {
+					continue
+				}
+				if math.Abs(p.Speed-c.want[p.CurrentIndex]) > 1e-9 {
+					t.Fatalf("路段 %d 的速度 = %.2f km/h，期望 %.2f", p.CurrentIndex, p.Speed, c.want[p.CurrentIndex])
+				}
+			}
+		})
+	}
+}
+
+func TestSegmentSpeedPrecedence(t *testing.T) {
+	route := pacedRoute()
+	workout := &Workout{Repeats: 1, Work: WorkoutStep{Duration: 60, Speed: 15}}
+
+	cases := []struct {
+		name    string
+		workout *Workout
+		index   int
+		params  motionParams
+		want    float64
+	}{
+		{"路线点自带速度", nil, 1, motionParams{speed: 8, speedScale: 1}, 12},
+		{"全局速度", nil, 2, motionParams{speed: 8, speedScale: 1}, 8},
+		{"倍率缩放路线点速度", nil, 0, motionParams{speed: 12, speedScale: 1.5}, 9},
+		{"训练阶段优先", workout, 1, motionParams{speed: 8, speedScale: 1}, 15},
+		{"最低速度", nil, 2, motionParams{speed: 0.1, speedScale: 1}, 0.5},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newMotionState(route, GPSNoiseConfig{}, 1, c.workout)
+			m.pointIndex = c.index
+			if got := m.segmentSpeed(c.params, 0); got != c.want {
+				t.Errorf("速度 = %.2f km/h，期望 %.2f", got, c.want)
+			}
+		})
+	}
+}
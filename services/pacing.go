@@ -0,0 +1,103 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxPaceFactor 目标完成时间模式下速度相对计划配速的最大倍数，暂停过久时不会无限加速
+const maxPaceFactor = 3.0
+
+// pacePlan 目标完成时间模式的计划配速
+//
+// 每一步按剩余路程在计划配速下所需的时间与距离目标剩余的时间之比缩放速度，
+// 速度波动、暂停、停留和设备下发延迟造成的偏差在之后的路程中逐步追回。
+type pacePlan struct {
+	nominal      float64   // 未指定速度的路段的计划速度 km/h
+	loopKM       float64   // 一圈的长度 km
+	segmentHours []float64 // 各路段按计划配速所需的时间 h
+	suffixHours  []float64 // suffixHours[i] 为路线点 i 到终点所需的时间 h
+	suffixDwell  []float64 // suffixDwell[i] 为路线点 i 之后（不含 i）各点停留时间之和 s
+}
+
+// newPacePlan 计算计划配速，路线点自带速度的路段按该速度，其余路段按 nominal km/h
+func newPacePlan(route []Point, nominal float64) *pacePlan {
+	n := len(route)
+	plan := &pacePlan{
+		nominal:      nominal,
+		segmentHours: make([]float64, n),
+		suffixHours:  make([]float64, n),
+		suffixDwell:  make([]float64, n),
+	}
+	for i := n - 2; i >= 0; i-- {
+		a, b := route[i], route[i+1]
+		distance := haversine(a.Lat, a.Lon, b.Lat, b.Lon)
+		speed := nominal
+		if a.Speed > 0 {
+			speed = a.Speed
+		}
+		var dwell float64
+		// 与 step 一致：过短的路段直接跳过，终点的停留也随之跳过
+		if distance >= 0.0001 {
+			plan.segmentHours[i] = distance / speed
+			dwell = b.Dwell
+		}
+		plan.loopKM += distance
+		plan.suffixHours[i] = plan.suffixHours[i+1] + plan.segmentHours[i]
+		plan.suffixDwell[i] = plan.suffixDwell[i+1] + dwell
+	}
+	return plan
+}
+
+// byDistance 目标距离是否先于全部圈数到达，此时剩余路程按整圈的平均配速和停留折算
+func (p *pacePlan) byDistance(loopCount int, goalKM float64) bool {
+	return goalKM > 0 && p.loopKM > 0 && (loopCount <= 0 || goalKM < float64(loopCount)*p.loopKM)
+}
+
+// factor 当前应使用的速度倍数，remaining 为距离目标完成时间的剩余时间
+func (p *pacePlan) factor(m *motionState, params motionParams, remaining time.Duration) float64 {
+	var planned, dwell float64
+	if p.byDistance(params.loopCount, params.goal.Distance) {
+		loops := max(params.goal.Distance-m.distanceKM, 0) / p.loopKM
+		planned = loops * p.suffixHours[0]
+		dwell = loops * p.suffixDwell[0]
+	} else {
+		i := min(m.pointIndex, len(p.segmentHours)-1)
+		loops := float64(max(params.loopCount-m.currentLoop, 0))
+		planned = p.segmentHours[i]*(1-m.progress) + p.suffixHours[min(i+1, len(p.suffixHours)-1)] + loops*p.suffixHours[0]
+		dwell = p.suffixDwell[i] + loops*p.suffixDwell[0]
+	}
+	dwell += m.dwellRemaining.Seconds()
+
+	available := remaining.Hours() - dwell/3600
+	if planned <= 0 {
+		return 1
+	}
+	if available <= planned/maxPaceFactor {
+		return maxPaceFactor
+	}
+	return planned / available
+}
+
+// targetPace 按目标完成时间计算计划平均速度 km/h，停留时间从目标中扣除；
+// 设置了目标距离且先于全部圈数到达时按目标距离计算
+func targetPace(route []Point, loopCount int, goalKM float64, target time.Duration, workout *Workout) (float64, error) {
+	if workout != nil {
+		return 0, fmt.Errorf("目标完成时间不能与间歇训练同时使用")
+	}
+	plan := newPacePlan(route, 1)
+	var loops float64
+	switch {
+	case plan.byDistance(loopCount, goalKM):
+		loops = goalKM / plan.loopKM
+	case loopCount > 0:
+		loops = float64(loopCount)
+	default:
+		return 0, fmt.Errorf("目标完成时间需要指定循环圈数或目标距离")
+	}
+	moving := target.Hours() - loops*plan.suffixDwell[0]/3600
+	if moving <= 0 {
+		return 0, fmt.Errorf("目标完成时间 %s 短于路线停留时间之和", target)
+	}
+	return plan.loopKM * loops / moving, nil
+}
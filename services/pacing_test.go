@@ -0,0 +1,99 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTargetFinishAccuracy(t *testing.T) {
+	dwellRoute := squareRoute()
+	dwellRoute[2].Dwell = 20
+
+	cases := []struct {
+		name      string
+		route     []Point
+		loops     int
+		goal      RunGoal
+		variance  float64
+		target    time.Duration
+		pauseAt   time.Duration // 0 表示不暂停
+		pause     time.Duration
+		tolerance time.Duration
+	}{
+		{"两圈", squareRoute(), 2, RunGoal{}, 0, 5 * time.Minute, 0, 0, 300 * time.Millisecond},
+		{"速度波动", squareRoute(), 2, RunGoal{}, 3, 5 * time.Minute, 0, 0, time.Second},
+		{"路线点停留", dwellRoute, 2, RunGoal{}, 0, 4 * time.Minute, 0, 0, 300 * time.Millisecond},
+		{"目标距离", squareRoute(), 0, RunGoal{Distance: 0.6}, 0, 4 * time.Minute, 0, 0, 300 * time.Millisecond},
+		{"中途暂停", squareRoute(), 2, RunGoal{}, 1, 5 * time.Minute, time.Minute, 30 * time.Second, time.Second},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			run := newManualRun(t)
+			run.service.SetRandomization(c.variance, 0)
+			run.service.SetLoopCount(c.loops)
+			if err := run.service.SetRunGoal(c.goal); err != nil {
+				t.Fatalf("设置目标失败: %v", err)
+			}
+			if err := run.service.SetTargetFinish(c.target); err != nil {
+				t.Fatalf("设置目标完成时间失败: %v", err)
+			}
+			run.start(c.route, 0)
+			if c.pauseAt > 0 {
+				run.advance(c.pauseAt)
+				run.service.PauseRun()
+				run.advance(c.pause)
+				run.service.ResumeRun()
+			}
+			_, last := run.finish(2 * c.target)
+
+			// 目标完成时间含暂停，位置事件中的跑步时间不含
+			finished := time.Duration(last.ElapsedTimeMs)*time.Millisecond + c.pause
+			if d := finished - c.target; d < -c.tolerance || d > c.tolerance {
+				t.Errorf("完成时间 = %s，目标 %s，偏差 %s", finished, c.target, d)
+			}
+		})
+	}
+}
+
+func TestTargetPace(t *testing.T) {
+	route := squareRoute()
+	loopKM := routeDistance(route)
+	dwellRoute := squareRoute()
+	dwellRoute[1].Dwell = 30
+
+	cases := []struct {
+		name    string
+		route   []Point
+		loops   int
+		goalKM  float64
+		target  time.Duration
+		workout *Workout
+		want    float64 // km/h，0 表示应返回错误
+	}{
+		{"按圈数", route, 3, 0, 6 * time.Minute, nil, 3 * loopKM / 0.1},
+		{"按目标距离", route, 0, 1, 6 * time.Minute, nil, 10},
+		{"圈数先于目标距离", route, 1, 5, 6 * time.Minute, nil, loopKM / 0.1},
+		{"扣除停留时间", dwellRoute, 2, 0, 6 * time.Minute, nil, 2 * loopKM / 0.1 * 6 / 5},
+		{"未指定圈数和距离", route, 0, 0, 6 * time.Minute, nil, 0},
+		{"停留长于目标", dwellRoute, 20, 0, 6 * time.Minute, nil, 0},
+		{"与间歇训练同时使用", route, 1, 0, 6 * time.Minute, &Workout{Repeats: 1}, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := targetPace(c.route, c.loops, c.goalKM, c.target, c.workout)
+			if c.want == 0 {
+				if err == nil {
+					t.Errorf("期望返回错误，得到 %.3f km/h", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("targetPace 失败: %v", err)
+			}
+			if math.Abs(got-c.want) > 1e-9 {
+				t.Errorf("计划速度 = %.4f km/h，期望 %.4f", got, c.want)
+			}
+		})
+	}
+}
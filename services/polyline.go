@@ -0,0 +1,172 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DecodePolyline 解码 Google 编码折线
+//
+// precision 为坐标小数位数：Google 为 5，OSRM/Valhalla 等为 6。
+// from 为折线的坐标系，返回值统一转换为 WGS84。
+func DecodePolyline(encoded string, precision int, from CoordSystem) ([]Point, error) {
+	factor, err := polylineFactor(precision)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []Point
+	var lat, lon int64
+	for i := 0; i < len(encoded); {
+		dLat, next, err := decodePolylineValue(encoded, i)
+		if err != nil {
+			return nil, err
+		}
+		dLon, next, err := decodePolylineValue(encoded, next)
+		if err != nil {
+			return nil, err
+		}
+		i = next
+
+		lat += dLat
+		lon += dLon
+		points = append(points, Point{Lat: float64(lat) / factor, Lon: float64(lon) / factor})
+	}
+	return TransformPoints(points, from, CoordWGS84), nil
+}
+
+// EncodePolyline 将 WGS84 路线点编码为指定坐标系和精度的 Google 编码折线
+func EncodePolyline(points []Point, precision int, to CoordSystem) (string, error) {
+	factor, err := polylineFactor(precision)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	var prevLat, prevLon int64
+	for _, p := range TransformPoints(points, CoordWGS84, to) {
+		lat := int64(math.Round(p.Lat * factor))
+		lon := int64(math.Round(p.Lon * factor))
+		encodePolylineValue(&sb, lat-prevLat)
+		encodePolylineValue(&sb, lon-prevLon)
+		prevLat, prevLon = lat, lon
+	}
+	return sb.String(), nil
+}
+
+func polylineFactor(precision int) (float64, error) {
+	if precision < 1 || precision > 7 {
+		return 0, fmt.Errorf("折线精度必须在 1 到 7 之间")
+	}
+	return math.Pow10(precision), nil
+}
+
+// decodePolylineValue 从 start 开始解码一个有符号值，返回值和下一个值的起始位置
+func decodePolylineValue(s string, start int) (int64, int, error) {
+	var result int64
+	var shift uint
+	for i := start; i < len(s); i++ {
+		b := int64(s[i]) - 63
+		if b < 0 || b > 0x3f {
+			return 0, 0, fmt.Errorf("折线第 %d 个字符无效: %q", i+1, s[i])
+		}
+		result |= (b & 0x1f) << shift
+		shift += 5
+		if b < 0x20 {
+			if result&1 != 0 {
+				return ^(result >> 1), i + 1, nil
+			}
+			return result >> 1, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("折线数据不完整")
+}
+
+func encodePolylineValue(sb *strings.Builder, v int64) {
+	u := v << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		sb.WriteByte(byte((0x20 | (u & 0x1f)) + 63))
+		u >>= 5
+	}
+	sb.WriteByte(byte(u + 63))
+}
+
+// DecodeCoordinateString 解析高德、百度 Web 服务返回的坐标串
+//
+// 格式为以分号分隔的"经度,纬度"，如 "116.481,39.989;116.465,39.998"。
+// 多段路径的坐标串可以直接以分号拼接后传入。from 为坐标串的坐标系，返回值统一转换为 WGS84。
+func DecodeCoordinateString(s string, from CoordSystem) ([]Point, error) {
+	var points []Point
+	for _, pair := range strings.Split(s, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		fields := strings.Split(pair, ",")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("无效的坐标: %q", pair)
+		}
+		lon, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的经度: %q", fields[0])
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的纬度: %q", fields[1])
+		}
+		points = append(points, Point{Lat: lat, Lon: lon})
+	}
+	return TransformPoints(points, from, CoordWGS84), nil
+}
+
+// EncodeCoordinateString 将 WGS84 路线点编码为指定坐标系的"经度,纬度;经度,纬度"坐标串，保留 6 位小数
+func EncodeCoordinateString(points []Point, to CoordSystem) string {
+	parts := make([]string, 0, len(points))
+	for _, p := range TransformPoints(points, CoordWGS84, to) {
+		parts = append(parts, strconv.FormatFloat(p.Lon, 'f', 6, 64)+","+strconv.FormatFloat(p.Lat, 'f', 6, 64))
+	}
+	return strings.Join(parts, ";")
+}
+
+// DecodeTencentPolyline 解压腾讯位置服务返回的前向差分坐标数组
+//
+// 数组形如 [纬度, 经度, Δ纬度×1e6, Δ经度×1e6, ...]，坐标系通常为 GCJ02，返回值统一转换为 WGS84。
+func DecodeTencentPolyline(coors []float64, from CoordSystem) ([]Point, error) {
+	if len(coors)%2 != 0 {
+		return nil, fmt.Errorf("坐标数组长度必须为偶数")
+	}
+
+	values := append([]float64(nil), coors...)
+	for i := 2; i < len(values); i++ {
+		values[i] = values[i-2] + values[i]/1e6
+	}
+
+	points := make([]Point, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		points = append(points, Point{Lat: values[i], Lon: values[i+1]})
+	}
+	return TransformPoints(points, from, CoordWGS84), nil
+}
+
+// EncodeTencentPolyline 将 WGS84 路线点压缩为指定坐标系的腾讯前向差分坐标数组
+func EncodeTencentPolyline(points []Point, to CoordSystem) []float64 {
+	converted := TransformPoints(points, CoordWGS84, to)
+	out := make([]float64, 0, len(converted)*2)
+	var prevLat, prevLon int64
+	for i, p := range converted {
+		lat := int64(math.Round(p.Lat * 1e6))
+		lon := int64(math.Round(p.Lon * 1e6))
+		if i == 0 {
+			out = append(out, float64(lat)/1e6, float64(lon)/1e6)
+		} else {
+			out = append(out, float64(lat-prevLat), float64(lon-prevLon))
+		}
+		prevLat, prevLon = lat, lon
+	}
+	return out
+}
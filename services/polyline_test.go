@@ -0,0 +1,136 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+// polylineSample Google 折线算法文档中的示例路线
+var polylineSample = []Point{{Lat: 38.5, Lon: -120.2}, {Lat: 40.7, Lon: -120.95}, {Lat: 43.252, Lon: -126.453}}
+
+func assertPointsNear(t *testing.T, got, want []Point, tolerance float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("得到 %d 个点，期望 %d 个", len(got), len(want))
+	}
+	for i := range want {
+		if math.Abs(got[i].Lat-want[i].Lat) > tolerance || math.Abs(got[i].Lon-want[i].Lon) > tolerance {
+			t.Errorf("第 %d 个点 = (%.7f, %.7f)，期望 (%.7f, %.7f)", i, got[i].Lat, got[i].Lon, want[i].Lat, want[i].Lon)
+		}
+	}
+}
+
+func TestPolylineKnownEncoding(t *testing.T) {
+	const encoded = "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+	got, err := EncodePolyline(polylineSample, 5, CoordWGS84)
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	if got != encoded {
+		t.Errorf("编码结果 = %q，期望 %q", got, encoded)
+	}
+	points, err := DecodePolyline(encoded, 5, CoordWGS84)
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	assertPointsNear(t, points, polylineSample, 0)
+}
+
+func TestPolylineRoundTrip(t *testing.T) {
+	route := []Point{{Lat: 39.908823, Lon: 116.39747}, {Lat: 39.9101234, Lon: 116.4012345}, {Lat: 39.8999999, Lon: 116.3800001}}
+	cases := []struct {
+		name      string
+		precision int
+		coord     CoordSystem
+	}{
+		{"精度 5", 5, CoordWGS84},
+		{"精度 6", 6, CoordWGS84},
+		{"精度 6 GCJ02", 6, CoordGCJ02},
+		{"精度 5 BD09", 5, CoordBD09},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			encoded, err := EncodePolyline(route, c.precision, c.coord)
+			if err != nil {
+				t.Fatalf("编码失败: %v", err)
+			}
+			points, err := DecodePolyline(encoded, c.precision, c.coord)
+			if err != nil {
+				t.Fatalf("解码失败: %v", err)
+			}
+			// 编码时按精度取整，误差不超过半个最小单位
+			assertPointsNear(t, points, route, 0.5*math.Pow10(-c.precision)+1e-9)
+		})
+	}
+}
+
+func TestPolylineErrors(t *testing.T) {
+	for _, precision := range []int{0, 8} {
+		if _, err := EncodePolyline(polylineSample, precision, CoordWGS84); err == nil {
+			t.Errorf("精度 %d 编码应返回错误", precision)
+		}
+		if _, err := DecodePolyline("??", precision, CoordWGS84); err == nil {
+			t.Errorf("精度 %d 解码应返回错误", precision)
+		}
+	}
+	for _, encoded := range []string{"_p~iF~ps|", "_p~iF", "_p~iF~ps|U ", "_p~iF~ps|U\x7f?"} {
+		if _, err := DecodePolyline(encoded, 5, CoordWGS84); err == nil {
+			t.Errorf("%q 解码应返回错误", encoded)
+		}
+	}
+	if points, err := DecodePolyline("", 5, CoordWGS84); err != nil || len(points) != 0 {
+		t.Errorf("空折线解码 = %v, %v", points, err)
+	}
+}
+
+func TestTencentPolyline(t *testing.T) {
+	// 腾讯位置服务文档中的压缩示例：第二个点为第一个点加上差分值 / 1e6
+	points, err := DecodeTencentPolyline([]float64{39.984154, 116.30749, 134, -1240, -200, 0}, CoordWGS84)
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	assertPointsNear(t, points, []Point{
+		{Lat: 39.984154, Lon: 116.30749},
+		{Lat: 39.984288, Lon: 116.30625},
+		{Lat: 39.984088, Lon: 116.30625},
+	}, 1e-9)
+
+	if _, err := DecodeTencentPolyline([]float64{39.98, 116.30, 134}, CoordWGS84); err == nil {
+		t.Error("奇数长度的坐标数组应返回错误")
+	}
+
+	route := []Point{{Lat: 39.908823, Lon: 116.39747}, {Lat: 39.9101234, Lon: 116.4012345}, {Lat: 39.8999999, Lon: 116.3800001}}
+	for _, cs := range []CoordSystem{CoordWGS84, CoordGCJ02} {
+		coors := EncodeTencentPolyline(route, cs)
+		if len(coors) != 2*len(route) {
+			t.Fatalf("%s: 压缩后长度 %d，期望 %d", cs, len(coors), 2*len(route))
+		}
+		// 差分值为整数
+		for i := 2; i < len(coors); i++ {
+			if coors[i] != math.Trunc(coors[i]) {
+				t.Errorf("%s: 第 %d 个差分值 %v 不是整数", cs, i, coors[i])
+			}
+		}
+		decoded, err := DecodeTencentPolyline(coors, cs)
+		if err != nil {
+			t.Fatalf("%s: 解码失败: %v", cs, err)
+		}
+		assertPointsNear(t, decoded, route, 0.5e-6+1e-9)
+	}
+}
+
+func TestCoordinateString(t *testing.T) {
+	points, err := DecodeCoordinateString(" 116.481,39.989;116.465, 39.998;;", CoordWGS84)
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	assertPointsNear(t, points, []Point{{Lat: 39.989, Lon: 116.481}, {Lat: 39.998, Lon: 116.465}}, 0)
+	if s := EncodeCoordinateString(points, CoordWGS84); s != "116.481000,39.989000;116.465000,39.998000" {
+		t.Errorf("编码结果 = %q", s)
+	}
+	for _, s := range []string{"116.481", "116.481,39.989,10", "abc,39.989", "116.481,abc"} {
+		if _, err := DecodeCoordinateString(s, CoordWGS84); err == nil {
+			t.Errorf("%q 应返回错误", s)
+		}
+	}
+}
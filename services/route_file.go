@@ -0,0 +1,139 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Route 带名称的路线
+type Route struct {
+	Name   string  `json:"name"`
+	Points []Point `json:"points"`
+}
+
+// LoadRoutes 读取路线文件中的全部路线，按扩展名识别格式
+//
+// 支持的格式：
+//   - .json  点数组 [{"lat":..,"lon":..}]，经度字段也可写作 lng，坐标可以是数字或数字字符串
+//   - .gpx   GPX 1.1 轨迹、路线和航点
+//   - .kml   KML LineString、gx:Track 和点地标
+//   - .kmz   压缩的 KML
+//   - .geojson GeoJSON LineString、MultiLineString 和 FeatureCollection
+//   - .csv   CSV 表格，自动识别分隔符、表头和经纬度列，坐标系为 WGS84
+//   - .ghostroute 带版本和元数据的路线文件，见 GhostRoute
+func LoadRoutes(path string) ([]Route, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取路线文件失败: %w", err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		points, err := parsePointsJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		return []Route{{Name: name, Points: points}}, nil
+	case ".gpx":
+		return ParseGPX(bytes.NewReader(data))
+	case ".kml":
+		return ParseKML(bytes.NewReader(data))
+	case ".kmz":
+		return ParseKMZ(data)
+	case ".geojson":
+		return ParseGeoJSON(data)
+	case GhostRouteExt:
+		gr, err := ParseGhostRoute(data)
+		if err != nil {
+			return nil, err
+		}
+		return []Route{{Name: gr.Name, Points: gr.Points}}, nil
+	case ".csv":
+		points, err := ParseCSV(bytes.NewReader(data), CSVOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return []Route{{Name: name, Points: points}}, nil
+	default:
+		return nil, fmt.Errorf("不支持的路线文件格式: %s", ext)
+	}
+}
+
+// LoadRouteFile 读取路线文件，返回其中第一条至少包含 2 个点的路线
+func LoadRouteFile(path string) ([]Point, error) {
+	routes, err := LoadRoutes(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, route := range routes {
+		if len(route.Points) >= 2 {
+			return route.Points, nil
+		}
+	}
+	return nil, fmt.Errorf("路线点数量不足，至少需要 2 个点")
+}
+
+// jsonCoord 点数组中的一个坐标值，可以是数字或数字字符串
+type jsonCoord float64
+
+func (c *jsonCoord) UnmarshalJSON(data []byte) error {
+	var v float64
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return fmt.Errorf("坐标 %q 不是数字", s)
+		}
+		v = f
+	} else if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("坐标 %s 不是数字", data)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("坐标 %s 不是有限数值", data)
+	}
+	*c = jsonCoord(v)
+	return nil
+}
+
+// parsePointsJSON 解析点数组 JSON，坐标可以是数字或数字字符串，缺少坐标或超出范围时返回错误
+func parsePointsJSON(data []byte) ([]Point, error) {
+	var raw []struct {
+		Lat *jsonCoord `json:"lat"`
+		Lon *jsonCoord `json:"lon"`
+		Lng *jsonCoord `json:"lng"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析路线 JSON 失败: %w", err)
+	}
+
+	points := make([]Point, 0, len(raw))
+	for i, p := range raw {
+		if p.Lat == nil {
+			return nil, fmt.Errorf("第 %d 个路线点缺少纬度", i+1)
+		}
+		lon := p.Lon
+		if lon == nil {
+			lon = p.Lng
+		}
+		if lon == nil {
+			return nil, fmt.Errorf("第 %d 个路线点缺少经度", i+1)
+		}
+		point := Point{Lat: float64(*p.Lat), Lon: float64(*lon)}
+		if math.Abs(point.Lat) > 90 || math.Abs(point.Lon) > 180 {
+			return nil, fmt.Errorf("第 %d 个路线点坐标 (%g, %g) 超出范围", i+1, point.Lat, point.Lon)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
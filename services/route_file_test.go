@@ -0,0 +1,45 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParsePointsJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		want []Point
+		err  string
+	}{
+		{"数字", `[{"lat":30.1,"lon":120.2},{"lat":-30,"lng":-120}]`, []Point{{Lat: 30.1, Lon: 120.2}, {Lat: -30, Lon: -120}}, ""},
+		{"数字字符串", `[{"lat":"30.1","lng":" 120.2 "},{"lat":"90","lon":"-180"}]`, []Point{{Lat: 30.1, Lon: 120.2}, {Lat: 90, Lon: -180}}, ""},
+		{"lon 优先于 lng", `[{"lat":30,"lon":120,"lng":121}]`, []Point{{Lat: 30, Lon: 120}}, ""},
+		{"缺少纬度", `[{"lat":30,"lon":120},{"lon":120}]`, nil, "第 2 个路线点缺少纬度"},
+		{"缺少经度", `[{"lat":30}]`, nil, "第 1 个路线点缺少经度"},
+		{"经度为 null", `[{"lat":30,"lon":null}]`, nil, "缺少经度"},
+		{"纬度超出范围", `[{"lat":91,"lon":120}]`, nil, "超出范围"},
+		{"经度超出范围", `[{"lat":30,"lng":"180.5"}]`, nil, "超出范围"},
+		{"非数字字符串", `[{"lat":"abc","lon":120}]`, nil, "不是数字"},
+		{"布尔值", `[{"lat":true,"lon":120}]`, nil, "不是数字"},
+		{"非数组", `{"lat":30,"lon":120}`, nil, "解析路线 JSON 失败"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			points, err := parsePointsJSON([]byte(c.data))
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("错误 = %v，期望包含 %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if !slices.Equal(points, c.want) {
+				t.Errorf("解析结果 = %+v，期望 %+v", points, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// RouteFormatService 路线格式导入导出服务
+type RouteFormatService struct{}
+
+func NewRouteFormatService() *RouteFormatService {
+	return &RouteFormatService{}
+}
+
+// ImportGPX 解析 GPX 文本，返回其中的全部路线
+func (s *RouteFormatService) ImportGPX(content string) ([]Route, error) {
+	routes, err := ParseGPX(strings.NewReader(content))
+	if err != nil {
+		Log.Error("RouteFormatService", err.Error())
+		return nil, err
+	}
+	Log.Info("RouteFormatService", fmt.Sprintf("GPX 导入完成，共 %d 条路线", len(routes)))
+	return routes, nil
+}
+
+// ExportGPX 将路线导出为 GPX 文本
+func (s *RouteFormatService) ExportGPX(routes []Route) (string, error) {
+	var buf bytes.Buffer
+	if err := WriteGPX(&buf, routes); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ImportKML 解析 KML 文本，返回其中的全部路线
+func (s *RouteFormatService) ImportKML(content string) ([]Route, error) {
+	routes, err := ParseKML(strings.NewReader(content))
+	if err != nil {
+		Log.Error("RouteFormatService", err.Error())
+		return nil, err
+	}
+	Log.Info("RouteFormatService", fmt.Sprintf("KML 导入完成，共 %d 条路线", len(routes)))
+	return routes, nil
+}
+
+// ImportKMZ 解析 KMZ 压缩包，返回其中的全部路线
+func (s *RouteFormatService) ImportKMZ(data []byte) ([]Route, error) {
+	routes, err := ParseKMZ(data)
+	if err != nil {
+		Log.Error("RouteFormatService", err.Error())
+		return nil, err
+	}
+	Log.Info("RouteFormatService", fmt.Sprintf("KMZ 导入完成，共 %d 条路线", len(routes)))
+	return routes, nil
+}
+
+// ImportGeoJSON 解析 GeoJSON 文本，返回其中的全部路线
+func (s *RouteFormatService) ImportGeoJSON(content string) ([]Route, error) {
+	routes, err := ParseGeoJSON([]byte(content))
+	if err != nil {
+		Log.Error("RouteFormatService", err.Error())
+		return nil, err
+	}
+	Log.Info("RouteFormatService", fmt.Sprintf("GeoJSON 导入完成，共 %d 条路线", len(routes)))
+	return routes, nil
+}
+
+// ImportCSV 按选项解析 CSV 文本，返回 WGS84 路线点
+func (s *RouteFormatService) ImportCSV(content string, opts CSVOptions) ([]Point, error) {
+	points, err := ParseCSV(strings.NewReader(content), opts)
+	if err != nil {
+		Log.Error("RouteFormatService", err.Error())
+		return nil, err
+	}
+	Log.Info("RouteFormatService", fmt.Sprintf("CSV 导入完成，共 %d 个点", len(points)))
+	return points, nil
+}
+
+// ExportRoutesGeoJSON 将路线导出为 GeoJSON 文本
+func (s *RouteFormatService) ExportRoutesGeoJSON(routes []Route) (string, error) {
+	var buf bytes.Buffer
+	if err := WriteRoutesGeoJSON(&buf, routes); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ExportTrackGeoJSON 将跑步轨迹导出为 GeoJSON 文本
+func (s *RouteFormatService) ExportTrackGeoJSON(name string, track []TrackPoint) (string, error) {
+	var buf bytes.Buffer
+	if err := WriteTrackGeoJSON(&buf, name, track); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ExportTrackFIT 将跑步轨迹导出为 FIT 活动文件
+func (s *RouteFormatService) ExportTrackFIT(track []TrackPoint) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteTrackFIT(&buf, track); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportTrackTCX 将跑步轨迹导出为 TCX 文本
+func (s *RouteFormatService) ExportTrackTCX(name string, track []TrackPoint) (string, error) {
+	var buf bytes.Buffer
+	if err := WriteTrackTCX(&buf, name, track); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// SimplifyRoute 按米为单位的容差简化路线，返回简化后的点及点数、距离变化
+func (s *RouteFormatService) SimplifyRoute(points []Point, toleranceM float64) (RouteEditResult, error) {
+	if toleranceM <= 0 {
+		return RouteEditResult{}, fmt.Errorf("简化容差必须大于 0")
+	}
+	result, stats := SimplifyRoute(points, toleranceM)
+	Log.Info("RouteFormatService", fmt.Sprintf("路线简化完成（容差 %.1fm），%s", toleranceM, stats))
+	return RouteEditResult{Points: result, Stats: stats}, nil
+}
+
+// ResampleRoute 按固定间距重采样路线，返回重采样后的点及点数、距离变化
+func (s *RouteFormatService) ResampleRoute(points []Point, spacingM float64) (RouteEditResult, error) {
+	if spacingM <= 0 {
+		return RouteEditResult{}, fmt.Errorf("重采样间距必须大于 0")
+	}
+	result, stats := ResampleRoute(points, spacingM)
+	Log.Info("RouteFormatService", fmt.Sprintf("路线重采样完成（间距 %.1fm），%s", spacingM, stats))
+	return RouteEditResult{Points: result, Stats: stats}, nil
+}
+
+// DecodePolyline 解码 Google 编码折线，precision 为 5 或 6，from 为折线坐标系，返回 WGS84 路线点
+func (s *RouteFormatService) DecodePolyline(encoded string, precision int, from CoordSystem) ([]Point, error) {
+	cs, err := ParseCoordSystem(string(from))
+	if err != nil {
+		return nil, err
+	}
+	return DecodePolyline(strings.TrimSpace(encoded), precision, cs)
+}
+
+// EncodePolyline 将 WGS84 路线点编码为指定坐标系的 Google 编码折线
+func (s *RouteFormatService) EncodePolyline(points []Point, precision int, to CoordSystem) (string, error) {
+	cs, err := ParseCoordSystem(string(to))
+	if err != nil {
+		return "", err
+	}
+	return EncodePolyline(points, precision, cs)
+}
+
+// DecodeCoordinateString 解析高德、百度的"经度,纬度;经度,纬度"坐标串，返回 WGS84 路线点
+func (s *RouteFormatService) DecodeCoordinateString(content string, from CoordSystem) ([]Point, error) {
+	cs, err := ParseCoordSystem(string(from))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeCoordinateString(content, cs)
+}
+
+// EncodeCoordinateString 将 WGS84 路线点编码为指定坐标系的坐标串
+func (s *RouteFormatService) EncodeCoordinateString(points []Point, to CoordSystem) (string, error) {
+	cs, err := ParseCoordSystem(string(to))
+	if err != nil {
+		return "", err
+	}
+	return EncodeCoordinateString(points, cs), nil
+}
+
+// DecodeTencentPolyline 解压腾讯前向差分坐标数组，返回 WGS84 路线点
+func (s *RouteFormatService) DecodeTencentPolyline(coors []float64, from CoordSystem) ([]Point, error) {
+	cs, err := ParseCoordSystem(string(from))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeTencentPolyline(coors, cs)
+}
+
+// EncodeTencentPolyline 将 WGS84 路线点压缩为指定坐标系的腾讯前向差分坐标数组
+func (s *RouteFormatService) EncodeTencentPolyline(points []Point, to CoordSystem) ([]float64, error) {
+	cs, err := ParseCoordSystem(string(to))
+	if err != nil {
+		return nil, err
+	}
+	return EncodeTencentPolyline(points, cs), nil
+}
+
+// LoadRouteFile 读取本地路线文件，返回其中的全部路线
+func (s *RouteFormatService) LoadRouteFile(path string) ([]Route, error) {
+	return LoadRoutes(path)
+}
@@ -0,0 +1,470 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// routeMigrationMarker 已完成 localStorage 迁移的标记文件
+const routeMigrationMarker = ".localstorage-migrated"
+
+// StoredRoute 路线库中的路线，坐标为 WGS84
+type StoredRoute struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Points     []Point    `json:"points"`
+	Tags       []string   `json:"tags"`
+	Defaults   *RunParams `json:"defaults,omitempty"`
+	Notes      string     `json:"notes,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ModifiedAt time.Time  `json:"modifiedAt"`
+}
+
+// RouteSummary 路线列表项，不含路线点
+type RouteSummary struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Tags       []string  `json:"tags"`
+	PointCount int       `json:"pointCount"`
+	Distance   float64   `json:"distance"` // km
+	CreatedAt  time.Time `json:"createdAt"`
+	ModifiedAt time.Time `json:"modifiedAt"`
+}
+
+// RouteService 路线库服务，每条路线保存为 routes 目录下的一个 .ghostroute 文件，文件名即路线 ID
+type RouteService struct {
+	mu  sync.Mutex
+	dir string
+	now func() time.Time
+}
+
+// NewRouteService 创建使用 ResolveAppDir("routes") 目录的路线库
+func NewRouteService() *RouteService {
+	return NewRouteServiceWithDir(ResolveAppDir("routes"))
+}
+
+// NewRouteServiceWithDir 创建使用指定目录的路线库
+func NewRouteServiceWithDir(dir string) *RouteService {
+	return &RouteService{dir: dir, now: time.Now}
+}
+
+// ListRoutes 列出全部路线，按修改时间从新到旧排列
+func (s *RouteService) ListRoutes() ([]RouteSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	routes, err := s.loadAll()
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]RouteSummary, 0, len(routes))
+	for _, r := range routes {
+		summaries = append(summaries, r.summary())
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].ModifiedAt.After(summaries[j].ModifiedAt)
+	})
+	return summaries, nil
+}
+
+// GetRoute 读取路线
+func (s *RouteService) GetRoute(id string) (StoredRoute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(id)
+}
+
+// CreateRoute 新建路线
+func (s *RouteService) CreateRoute(name string, points []Point, tags []string) (StoredRoute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return StoredRoute{}, fmt.Errorf("路线名称不能为空")
+	}
+	now := s.now()
+	route := StoredRoute{
+		Name:       name,
+		Points:     append([]Point{}, points...),
+		Tags:       normalizeTags(tags),
+		CreatedAt:  now,
+		ModifiedAt: now,
+	}
+	if err := s.create(&route); err != nil {
+		return StoredRoute{}, err
+	}
+	Log.Info("RouteService", fmt.Sprintf("已保存路线 %q，%d 个点", route.Name, len(route.Points)))
+	return route, nil
+}
+
+// UpdateRoute 替换路线点
+func (s *RouteService) UpdateRoute(id string, points []Point) (StoredRoute, error) {
+	return s.modify(id, func(r *StoredRoute) error {
+		r.Points = append([]Point{}, points...)
+		return nil
+	})
+}
+
+// RenameRoute 重命名路线
+func (s *RouteService) RenameRoute(id, name string) (StoredRoute, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return StoredRoute{}, fmt.Errorf("路线名称不能为空")
+	}
+	return s.modify(id, func(r *StoredRoute) error {
+		r.Name = name
+		return nil
+	})
+}
+
+// SetTags 设置路线标签，去除空白和重复项
+func (s *RouteService) SetTags(id string, tags []string) (StoredRoute, error) {
+	return s.modify(id, func(r *StoredRoute) error {
+		r.Tags = normalizeTags(tags)
+		return nil
+	})
+}
+
+// SetNotes 设置路线备注
+func (s *RouteService) SetNotes(id, notes string) (StoredRoute, error) {
+	return s.modify(id, func(r *StoredRoute) error {
+		r.Notes = notes
+		return nil
+	})
+}
+
+// SetDefaults 设置路线的默认跑步参数，传 nil 清除
+func (s *RouteService) SetDefaults(id string, defaults *RunParams) (StoredRoute, error) {
+	return s.modify(id, func(r *StoredRoute) error {
+		r.Defaults = defaults
+		return nil
+	})
+}
+
+// ExportRoute 将路线导出为 .ghostroute 文本，坐标转换为 to 坐标系
+func (s *RouteService) ExportRoute(id string, to CoordSystem) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	route, err := s.load(id)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := WriteGhostRoute(&buf, route.ghostRoute(), to); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ImportRoute 将 .ghostroute 文本（含旧版本）导入路线库，保留其中的创建和修改时间
+func (s *RouteService) ImportRoute(content string) (StoredRoute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gr, err := ParseGhostRoute([]byte(content))
+	if err != nil {
+		Log.Error("RouteService", err.Error())
+		return StoredRoute{}, err
+	}
+	route := s.fromGhostRoute("", gr)
+	if err := s.create(&route); err != nil {
+		return StoredRoute{}, err
+	}
+	Log.Info("RouteService", fmt.Sprintf("已导入路线 %q，%d 个点", route.Name, len(route.Points)))
+	return route, nil
+}
+
+// DuplicateRoute 复制路线，name 为空时使用"原名称 副本"
+func (s *RouteService) DuplicateRoute(id, name string) (StoredRoute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, err := s.load(id)
+	if err != nil {
+		return StoredRoute{}, err
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		name = src.Name + " 副本"
+	}
+	now := s.now()
+	route := src
+	route.Name = name
+	route.CreatedAt = now
+	route.ModifiedAt = now
+	if err := s.create(&route); err != nil {
+		return StoredRoute{}, err
+	}
+	return route, nil
+}
+
+// DeleteRoute 删除路线
+func (s *RouteService) DeleteRoute(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("路线不存在: %s", id)
+		}
+		return fmt.Errorf("删除路线失败: %w", err)
+	}
+	Log.Info("RouteService", fmt.Sprintf("已删除路线 %s", id))
+	return nil
+}
+
+// MigrateLocalStorage 导入旧版前端保存在 localStorage "routes" 键中的路线
+//
+// payload 为该键的原始内容，形如 {"routes":[{"name":..,"points":[..],"createdAt":毫秒}]}；
+// 自动保存的 last_route 不导入。旧版按名称区分路线，路线 ID 由名称确定，已存在的路线跳过，
+// 中途失败后重试不会重复导入。全部完成后写入标记，之后的调用直接返回 0。
+func (s *RouteService) MigrateLocalStorage(payload string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	marker := filepath.Join(s.dir, routeMigrationMarker)
+	if _, err := os.Stat(marker); err == nil {
+		return 0, nil
+	}
+
+	var legacy struct {
+		Routes []json.RawMessage `json:"routes"`
+	}
+	if payload = strings.TrimSpace(payload); payload != "" {
+		if err := json.Unmarshal([]byte(payload), &legacy); err != nil {
+			return 0, fmt.Errorf("解析旧路线数据失败: %w", err)
+		}
+	}
+
+	count := 0
+	for _, raw := range legacy.Routes {
+		// 旧版路线即 .ghostroute 版本 0，按格式迁移规则升级
+		gr, err := ParseGhostRoute(raw)
+		if err != nil {
+			return count, err
+		}
+		if gr.Name == "last_route" {
+			continue
+		}
+		id := legacyRouteID(gr.Name)
+		path, err := s.path(id)
+		if err != nil {
+			return count, err
+		}
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if gr.ModifiedAt.IsZero() {
+			gr.ModifiedAt = gr.CreatedAt
+		}
+		route := s.fromGhostRoute(id, gr)
+		if route.Points == nil {
+			route.Points = []Point{}
+		}
+		if err := s.save(route); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	if err := os.WriteFile(marker, []byte(s.now().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		return count, fmt.Errorf("写入迁移标记失败: %w", err)
+	}
+	Log.Info("RouteService", fmt.Sprintf("已从 localStorage 迁移 %d 条路线", count))
+	return count, nil
+}
+
+// legacyRouteID 由 localStorage 中的路线名称生成固定的路线 ID
+func legacyRouteID(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return "ls-" + hex.EncodeToString(sum[:8])
+}
+
+// modify 读取、修改并保存路线，同时更新修改时间
+func (s *RouteService) modify(id string, fn func(r *StoredRoute) error) (StoredRoute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	route, err := s.load(id)
+	if err != nil {
+		return StoredRoute{}, err
+	}
+	if err := fn(&route); err != nil {
+		return StoredRoute{}, err
+	}
+	route.ModifiedAt = s.now()
+	if err := s.save(route); err != nil {
+		return StoredRoute{}, err
+	}
+	return route, nil
+}
+
+// create 分配 ID 并保存新路线
+func (s *RouteService) create(route *StoredRoute) error {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Errorf("生成路线 ID 失败: %w", err)
+	}
+	route.ID = hex.EncodeToString(b[:])
+	if route.Points == nil {
+		route.Points = []Point{}
+	}
+	if route.Tags == nil {
+		route.Tags = []string{}
+	}
+	return s.save(*route)
+}
+
+func (s *RouteService) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", fmt.Errorf("无效的路线 ID: %q", id)
+	}
+	return filepath.Join(s.dir, id+GhostRouteExt), nil
+}
+
+// load 读取路线
+func (s *RouteService) load(id string) (StoredRoute, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return StoredRoute{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return StoredRoute{}, fmt.Errorf("路线不存在: %s", id)
+		}
+		return StoredRoute{}, fmt.Errorf("读取路线失败: %w", err)
+	}
+
+	gr, err := ParseGhostRoute(data)
+	if err != nil {
+		return StoredRoute{}, fmt.Errorf("路线 %s: %w", id, err)
+	}
+	return s.fromGhostRoute(id, gr), nil
+}
+
+func (s *RouteService) loadAll() ([]StoredRoute, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取路线目录失败: %w", err)
+	}
+
+	var routes []StoredRoute
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || filepath.Ext(name) != GhostRouteExt {
+			continue
+		}
+		route, err := s.load(strings.TrimSuffix(name, GhostRouteExt))
+		if err != nil {
+			// 单个文件损坏不影响列出其余路线
+			Log.Warn("RouteService", err.Error())
+			continue
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// save 先写临时文件再重命名，避免写入中断留下损坏的路线文件
+func (s *RouteService) save(route StoredRoute) error {
+	path, err := s.path(route.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("创建路线目录失败: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGhostRoute(&buf, route.ghostRoute(), CoordWGS84); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("保存路线失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("保存路线失败: %w", err)
+	}
+	return nil
+}
+
+func (r StoredRoute) ghostRoute() GhostRoute {
+	return GhostRoute{
+		Name:       r.Name,
+		Points:     r.Points,
+		Defaults:   r.Defaults,
+		Notes:      r.Notes,
+		Tags:       r.Tags,
+		CreatedAt:  r.CreatedAt,
+		ModifiedAt: r.ModifiedAt,
+	}
+}
+
+// fromGhostRoute 由文件内容构造路线，缺少时间时使用当前时间
+func (s *RouteService) fromGhostRoute(id string, gr GhostRoute) StoredRoute {
+	route := StoredRoute{
+		ID:         id,
+		Name:       gr.Name,
+		Points:     gr.Points,
+		Tags:       normalizeTags(gr.Tags),
+		Defaults:   gr.Defaults,
+		Notes:      gr.Notes,
+		CreatedAt:  gr.CreatedAt,
+		ModifiedAt: gr.ModifiedAt,
+	}
+	if route.CreatedAt.IsZero() {
+		route.CreatedAt = s.now()
+	}
+	if route.ModifiedAt.IsZero() {
+		route.ModifiedAt = route.CreatedAt
+	}
+	return route
+}
+
+func (r StoredRoute) summary() RouteSummary {
+	return RouteSummary{
+		ID:         r.ID,
+		Name:       r.Name,
+		Tags:       r.Tags,
+		PointCount: len(r.Points),
+		Distance:   routeDistance(r.Points),
+		CreatedAt:  r.CreatedAt,
+		ModifiedAt: r.ModifiedAt,
+	}
+}
+
+// normalizeTags 去除标签两端空白、空标签和重复项，保持原有顺序
+func normalizeTags(tags []string) []string {
+	out := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
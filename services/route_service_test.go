@@ -0,0 +1,55 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMigrateLocalStorageIdempotent(t *testing.T) {
+	NewLoggerService(nil)
+	dir := t.TempDir()
+	s := NewRouteServiceWithDir(dir)
+
+	const good = `{"name":"晨跑","points":[{"lat":30,"lng":120},{"lat":30.001,"lng":120.001}],"createdAt":1714546800000}`
+	const last = `{"name":"last_route","points":[{"lat":31,"lng":121}],"createdAt":1714546800000}`
+	const broken = `{"name":"夜跑","points":[{"lat":"北纬","lng":121}],"createdAt":1714546800000}`
+	const fixed = `{"name":"夜跑","points":[{"lat":31,"lng":121},{"lat":31.001,"lng":121.001}],"createdAt":1714550400000}`
+
+	// 第二条路线损坏，迁移中途失败且不写入标记
+	if n, err := s.MigrateLocalStorage(`{"routes":[` + good + `,` + last + `,` + broken + `]}`); err == nil || n != 1 {
+		t.Fatalf("迁移结果 = %d, %v，期望导入 1 条后报错", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, routeMigrationMarker)); err == nil {
+		t.Fatal("迁移失败时不应写入标记")
+	}
+
+	// 修复后重试，已导入的路线不重复导入
+	n, err := s.MigrateLocalStorage(`{"routes":[` + good + `,` + last + `,` + fixed + `]}`)
+	if err != nil || n != 1 {
+		t.Fatalf("重试迁移结果 = %d, %v，期望只导入剩余的 1 条", n, err)
+	}
+	routes, err := s.ListRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("路线库有 %d 条路线，期望 2 条: %+v", len(routes), routes)
+	}
+	morning, err := s.GetRoute(legacyRouteID("晨跑"))
+	if err != nil {
+		t.Fatalf("按名称生成的 ID 读取路线失败: %v", err)
+	}
+	if created := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC); !morning.CreatedAt.Equal(created) || !morning.ModifiedAt.Equal(created) {
+		t.Errorf("创建 %v 修改 %v，期望都为 %v", morning.CreatedAt, morning.ModifiedAt, created)
+	}
+	if len(morning.Points) != 2 || morning.Points[1].Lon != 120.001 {
+		t.Errorf("路线点 = %+v", morning.Points)
+	}
+
+	// 完成后写入标记，再次调用直接返回
+	if n, err := s.MigrateLocalStorage(`{"routes":[` + good + `]}`); err != nil || n != 0 {
+		t.Errorf("完成后再次迁移 = %d, %v，期望 0", n, err)
+	}
+}
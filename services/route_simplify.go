@@ -0,0 +1,198 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// RouteEditStats 路线处理前后的点数与距离
+type RouteEditStats struct {
+	PointsBefore   int     `json:"pointsBefore"`
+	PointsAfter    int     `json:"pointsAfter"`
+	DistanceBefore float64 `json:"distanceBefore"` // km
+	DistanceAfter  float64 `json:"distanceAfter"`  // km
+}
+
+func newRouteEditStats(before, after []Point) RouteEditStats {
+	return RouteEditStats{
+		PointsBefore:   len(before),
+		PointsAfter:    len(after),
+		DistanceBefore: routeDistance(before),
+		DistanceAfter:  routeDistance(after),
+	}
+}
+
+func (s RouteEditStats) String() string {
+	return fmt.Sprintf("点数 %d → %d，距离 %.0fm → %.0fm（%+.1fm）",
+		s.PointsBefore, s.PointsAfter, s.DistanceBefore*1000, s.DistanceAfter*1000,
+		(s.DistanceAfter-s.DistanceBefore)*1000)
+}
+
+// RouteEditResult 路线处理结果
+type RouteEditResult struct {
+	Points []Point        `json:"points"`
+	Stats  RouteEditStats `json:"stats"`
+}
+
+// isRouteAnchor 带停留时间或名称的路线点在简化和重采样时始终保留
+func isRouteAnchor(p Point) bool {
+	return p.Dwell > 0 || p.Label != ""
+}
+
+// SimplifyRoute 使用 Douglas-Peucker 算法简化路线，被删除的点偏离简化后路线不超过 toleranceM 米
+//
+// 起点、终点、带停留时间或名称的点以及速度变化的点始终保留。toleranceM <= 0 时原样返回。
+func SimplifyRoute(points []Point, toleranceM float64) ([]Point, RouteEditStats) {
+	if toleranceM <= 0 || len(points) < 3 {
+		result := append([]Point(nil), points...)
+		return result, newRouteEditStats(points, result)
+	}
+
+	proj := newLocalProjection(points)
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	for i, p := range points {
+		// 速度变化的点是配速分段的起点，同样保留
+		if isRouteAnchor(p) || (i > 0 && p.Speed != points[i-1].Speed) {
+			keep[i] = true
+		}
+	}
+
+	// 按已保留的点分段，对每段迭代执行 Douglas-Peucker
+	type span struct{ first, last int }
+	var stack []span
+	first := 0
+	for i := 1; i < len(points); i++ {
+		if keep[i] {
+			stack = append(stack, span{first, i})
+			first = i
+		}
+	}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if s.last-s.first < 2 {
+			continue
+		}
+
+		ax, ay := proj.xy(points[s.first])
+		bx, by := proj.xy(points[s.last])
+		farthest, maxDist := -1, toleranceM
+		for i := s.first + 1; i < s.last; i++ {
+			px, py := proj.xy(points[i])
+			if d := segmentDistance(px, py, ax, ay, bx, by); d > maxDist {
+				farthest, maxDist = i, d
+			}
+		}
+		if farthest < 0 {
+			continue
+		}
+		keep[farthest] = true
+		stack = append(stack, span{s.first, farthest}, span{farthest, s.last})
+	}
+
+	var result []Point
+	for i, p := range points {
+		if keep[i] {
+			result = append(result, p)
+		}
+	}
+	return result, newRouteEditStats(points, result)
+}
+
+// ResampleRoute 沿路线每隔 spacingM 米取一个点，补齐手绘路线的稀疏点并统一点距
+//
+// 新点的海拔和时间按距离线性插值，速度沿用所在路段起点的速度。起点、终点、带停留时间
+// 或名称的点以及速度变化的点始终保留，间距从这些点重新计算；拐角处的偏差不超过 spacingM。
+// spacingM <= 0 时原样返回。
+func ResampleRoute(points []Point, spacingM float64) ([]Point, RouteEditStats) {
+	if spacingM <= 0 || len(points) < 2 {
+		result := append([]Point(nil), points...)
+		return result, newRouteEditStats(points, result)
+	}
+
+	spacingKM := spacingM / 1000
+	result := []Point{points[0]}
+	sinceLast := 0.0 // 距上一个输出点的路程 km
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		segmentKM := haversine(a.Lat, a.Lon, b.Lat, b.Lon)
+
+		// 在本段内依次放置距上一个输出点 spacingKM 的新点
+		for pos := spacingKM - sinceLast; pos < segmentKM; pos += spacingKM {
+			result = append(result, interpolatePoint(a, b, pos/segmentKM))
+		}
+		if segmentKM > 0 {
+			sinceLast = math.Mod(sinceLast+segmentKM, spacingKM)
+		}
+
+		last := i == len(points)-1
+		if last || isRouteAnchor(b) || b.Speed != a.Speed {
+			// 与上一个新点几乎重合时由原始点替换，避免出现极短的路段
+			if prev := result[len(result)-1]; len(result) > 1 && !isRouteAnchor(prev) &&
+				haversine(prev.Lat, prev.Lon, b.Lat, b.Lon) < spacingKM*0.01 {
+				result = result[:len(result)-1]
+			}
+			result = append(result, b)
+			sinceLast = 0
+		}
+	}
+	return result, newRouteEditStats(points, result)
+}
+
+// interpolatePoint 在 a、b 之间按比例 t 插值，不带停留时间和名称
+func interpolatePoint(a, b Point, t float64) Point {
+	p := Point{
+		Lat:   a.Lat + (b.Lat-a.Lat)*t,
+		Lon:   a.Lon + (b.Lon-a.Lon)*t,
+		Ele:   a.Ele + (b.Ele-a.Ele)*t,
+		Speed: a.Speed,
+	}
+	if !a.Time.IsZero() && !b.Time.IsZero() {
+		p.Time = a.Time.Add(time.Duration(float64(b.Time.Sub(a.Time)) * t))
+	}
+	return p
+}
+
+// localProjection 以路线中心为原点的等距圆柱投影，单位米，适用于城市尺度的路线
+type localProjection struct {
+	lat0, lon0 float64
+	kx, ky     float64 // 每度经度、纬度对应的米数
+}
+
+func newLocalProjection(points []Point) localProjection {
+	minLat, maxLat := math.Inf(1), math.Inf(-1)
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		minLat, maxLat = math.Min(minLat, p.Lat), math.Max(maxLat, p.Lat)
+		minLon, maxLon = math.Min(minLon, p.Lon), math.Max(maxLon, p.Lon)
+	}
+	lat0 := (minLat + maxLat) / 2
+	return localProjection{
+		lat0: lat0,
+		lon0: (minLon + maxLon) / 2,
+		kx:   metersPerDegree * math.Cos(lat0*math.Pi/180),
+		ky:   metersPerDegree,
+	}
+}
+
+func (p localProjection) xy(pt Point) (x, y float64) {
+	return (pt.Lon - p.lon0) * p.kx, (pt.Lat - p.lat0) * p.ky
+}
+
+// point 将投影坐标还原为经纬度
+func (p localProjection) point(x, y float64) Point {
+	return Point{Lat: p.lat0 + y/p.ky, Lon: p.lon0 + x/p.kx}
+}
+
+// segmentDistance 点 (px, py) 到线段 (ax, ay)-(bx, by) 的距离
+func segmentDistance(px, py, ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	lenSq := dx*dx + dy*dy
+	if lenSq == 0 {
+		return math.Hypot(px-ax, py-ay)
+	}
+	t := math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/lenSq))
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
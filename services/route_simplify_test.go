@@ -0,0 +1,165 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+// eastRoute 从 (30, 120) 向东的直线路线，xs 为各点距起点的米数
+func eastRoute(xs ...float64) []Point {
+	proj := newLocalProjection([]Point{{Lat: 30, Lon: 120}})
+	points := make([]Point, len(xs))
+	for i, x := range xs {
+		points[i] = proj.point(x, 0)
+	}
+	return points
+}
+
+// maxDeviation 原始路线各点到简化后路线的最大距离（米）
+func maxDeviation(original, simplified []Point) float64 {
+	proj := newLocalProjection(original)
+	maxDist := 0.0
+	for _, p := range original {
+		px, py := proj.xy(p)
+		nearest := math.Inf(1)
+		for i := 1; i < len(simplified); i++ {
+			ax, ay := proj.xy(simplified[i-1])
+			bx, by := proj.xy(simplified[i])
+			nearest = math.Min(nearest, segmentDistance(px, py, ax, ay, bx, by))
+		}
+		maxDist = math.Max(maxDist, nearest)
+	}
+	return maxDist
+}
+
+func TestSimplifyRouteTolerance(t *testing.T) {
+	// 每米一个点、振幅 5 m 的波浪形轨迹
+	proj := newLocalProjection([]Point{{Lat: 30, Lon: 120}})
+	var route []Point
+	for x := 0.0; x <= 500; x++ {
+		route = append(route, proj.point(x, 5*math.Sin(x/20)))
+	}
+
+	for _, tolerance := range []float64{0.1, 0.5, 2, 5, 10} {
+		simplified, stats := SimplifyRoute(route, tolerance)
+		if stats.PointsBefore != len(route) || stats.PointsAfter != len(simplified) {
+			t.Errorf("容差 %v m: 统计点数 %d → %d 与实际不符", tolerance, stats.PointsBefore, stats.PointsAfter)
+		}
+		if len(simplified) >= len(route) {
+			t.Errorf("容差 %v m: 简化后仍有 %d 个点", tolerance, len(simplified))
+		}
+		if simplified[0] != route[0] || simplified[len(simplified)-1] != route[len(route)-1] {
+			t.Errorf("容差 %v m: 起点和终点应保留", tolerance)
+		}
+		if d := maxDeviation(route, simplified); d > tolerance+1e-6 {
+			t.Errorf("容差 %v m: 最大偏差 %.3f m", tolerance, d)
+		}
+	}
+}
+
+func TestSimplifyRouteKeepsAnchors(t *testing.T) {
+	cases := []struct {
+		name  string
+		edit  func(points []Point)
+		index int // 应保留的点
+	}{
+		{"停留点", func(p []Point) { p[3].Dwell = 30 }, 3},
+		{"命名点", func(p []Point) { p[5].Label = "补给站" }, 5},
+		{"速度变化", func(p []Point) {
+			for i := 4; i < len(p); i++ {
+				p[i].Speed = 12
+			}
+		}, 4},
+		{"速度恢复", func(p []Point) { p[2].Speed, p[3].Speed = 8, 8 }, 4},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// 直线上的中间点本应全部删除
+			route := eastRoute(0, 10, 20, 30, 40, 50, 60, 70)
+			c.edit(route)
+			simplified, _ := SimplifyRoute(route, 5)
+			found := false
+			for _, p := range simplified {
+				if p == route[c.index] {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("第 %d 个点被删除: %+v", c.index, simplified)
+			}
+			if len(simplified) > 4 {
+				t.Errorf("简化后有 %d 个点，其余中间点应删除", len(simplified))
+			}
+		})
+	}
+}
+
+func TestResampleRoute(t *testing.T) {
+	cases := []struct {
+		name    string
+		route   []Point
+		spacing float64
+		gaps    []float64 // 输出各段长度（米）
+	}{
+		{"整除", eastRoute(0, 50), 10, []float64{10, 10, 10, 10, 10}},
+		{"余下短段", eastRoute(0, 25), 10, []float64{10, 10, 5}},
+		{"跨越原始点", eastRoute(0, 4, 15, 30), 10, []float64{10, 10, 10}},
+		// 最后一个新点距终点 0.05 m，由终点替换
+		{"近似重合", eastRoute(0, 30.05), 10, []float64{10, 10, 10.05}},
+		// 距终点 0.5 m 超过间距的 1%，保留
+		{"不重合", eastRoute(0, 30.5), 10, []float64{10, 10, 10, 0.5}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resampled, stats := ResampleRoute(c.route, c.spacing)
+			if len(resampled) != len(c.gaps)+1 {
+				t.Fatalf("重采样后 %d 个点，期望 %d 个", len(resampled), len(c.gaps)+1)
+			}
+			if resampled[0] != c.route[0] || resampled[len(resampled)-1] != c.route[len(c.route)-1] {
+				t.Error("起点和终点应保持不变")
+			}
+			for i, want := range c.gaps {
+				a, b := resampled[i], resampled[i+1]
+				if d := haversine(a.Lat, a.Lon, b.Lat, b.Lon) * 1000; math.Abs(d-want) > 0.01 {
+					t.Errorf("第 %d 段长 %.3f m，期望 %.3f m", i, d, want)
+				}
+			}
+			if math.Abs(stats.DistanceAfter-stats.DistanceBefore) > 1e-6 {
+				t.Errorf("直线重采样后距离 %.4f km → %.4f km", stats.DistanceBefore, stats.DistanceAfter)
+			}
+		})
+	}
+}
+
+func TestResampleRouteKeepsAnchors(t *testing.T) {
+	route := eastRoute(0, 25, 40.05, 60)
+	route[1].Label = "路口"
+	route[2].Speed = 12
+	route[3].Speed = 12
+
+	resampled, _ := ResampleRoute(route, 10)
+	// 间距从保留点重新计算，路口和速度变化点之前的余段各自保留
+	want := []float64{0, 10, 20, 25, 35, 40.05, 50.05, 60}
+	if len(resampled) != len(want) {
+		t.Fatalf("重采样后 %d 个点，期望 %d 个: %+v", len(resampled), len(want), resampled)
+	}
+	proj := newLocalProjection([]Point{{Lat: 30, Lon: 120}})
+	for i, w := range want {
+		if x, _ := proj.xy(resampled[i]); math.Abs(x-w) > 0.01 {
+			t.Errorf("第 %d 个点位于 %.3f m，期望 %.3f m", i, x, w)
+		}
+	}
+	if resampled[3] != route[1] || resampled[5] != route[2] {
+		t.Error("路口和速度变化点应原样保留")
+	}
+	// 新点沿用所在路段起点的速度
+	for i, p := range resampled {
+		want := 0.0
+		if i >= 5 {
+			want = 12
+		}
+		if p.Speed != want {
+			t.Errorf("第 %d 个点速度 %v，期望 %v", i, p.Speed, want)
+		}
+	}
+}
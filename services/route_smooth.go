@@ -0,0 +1,281 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SmoothingMode 跑步轨迹的平滑方式
+type SmoothingMode string
+
+const (
+	SmoothingNone   SmoothingMode = "none"   // 直线连接路线点，拐角为尖角
+	SmoothingArc    SmoothingMode = "arc"    // 拐角处按转弯半径倒圆角，折返处按灯泡形掉头
+	SmoothingSpline SmoothingMode = "spline" // 向心 Catmull-Rom 样条，经过全部路线点
+)
+
+const (
+	// defaultTurnRadius 默认转弯半径 m
+	defaultTurnRadius = 3.0
+	// turnaroundAngle 转向角超过该值（弧度）时按折返处理，约 150°
+	turnaroundAngle = 150 * math.Pi / 180
+	// smoothArcStep 圆弧上相邻生成点的最大夹角，约 15°
+	smoothArcStep = math.Pi / 12
+	// smoothSplineStep 样条上相邻生成点的间距 m
+	smoothSplineStep = 2.0
+	// smoothSplineMaxSamples 每段样条最多生成的点数
+	smoothSplineMaxSamples = 32
+)
+
+// ParseSmoothingMode 解析平滑方式，不区分大小写，空串视为不平滑
+func ParseSmoothingMode(s string) (SmoothingMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "none", "off":
+		return SmoothingNone, nil
+	case "arc", "fillet":
+		return SmoothingArc, nil
+	case "spline", "catmullrom", "catmull-rom":
+		return SmoothingSpline, nil
+	default:
+		return "", fmt.Errorf("不支持的平滑方式: %s", s)
+	}
+}
+
+// SmoothRoute 按平滑方式加密路线，返回平滑后的路线点，以及每个点所在的原始路段起点下标
+//
+// 带停留时间或名称的点、起点和终点保持原样，跑步时一定会经过；其余拐角按 mode 处理。
+// 新生成的点不带停留时间和名称，海拔按位置插值，速度沿用所在原始路段起点的速度。
+func SmoothRoute(points []Point, mode SmoothingMode, turnRadius float64) ([]Point, []int) {
+	switch {
+	case len(points) < 3 || mode == SmoothingNone || mode == "":
+	case mode == SmoothingArc && turnRadius > 0:
+		return filletRoute(points, turnRadius)
+	case mode == SmoothingSpline:
+		return splineRoute(points)
+	}
+
+	result := append([]Point(nil), points...)
+	source := make([]int, len(points))
+	for i := range source {
+		source[i] = i
+	}
+	return result, source
+}
+
+// smoothBuilder 收集平滑后的点及其对应的原始路段
+type smoothBuilder struct {
+	proj   localProjection
+	points []Point
+	source []int
+}
+
+func (b *smoothBuilder) add(p Point, segment int) {
+	b.points = append(b.points, p)
+	b.source = append(b.source, segment)
+}
+
+// addXY 在原始路段 segment（points[segment] 到 points[segment+1]）附近添加投影坐标为 (x, y) 的点
+func (b *smoothBuilder) addXY(x, y float64, from, to Point, segment int) {
+	p := b.proj.point(x, y)
+	ax, ay := b.proj.xy(from)
+	bx, by := b.proj.xy(to)
+	t := 0.0
+	if dx, dy := bx-ax, by-ay; dx != 0 || dy != 0 {
+		t = math.Max(0, math.Min(1, ((x-ax)*dx+(y-ay)*dy)/(dx*dx+dy*dy)))
+	}
+	p.Ele = from.Ele + (to.Ele-from.Ele)*t
+	p.Speed = from.Speed
+	b.add(p, segment)
+}
+
+// filletRoute 在每个拐角处用与前后两段相切的圆弧替换尖角
+//
+// 圆弧切点离拐角不超过相邻两段长度的一半，段太短时自动减小半径；
+// 转向角超过 turnaroundAngle 时改为灯泡形掉头，见 addTurnaround。
+func filletRoute(points []Point, radius float64) ([]Point, []int) {
+	b := smoothBuilder{proj: newLocalProjection(points)}
+	b.add(points[0], 0)
+
+	for i := 1; i < len(points)-1; i++ {
+		prev, cur, next := points[i-1], points[i], points[i+1]
+		px, py := b.proj.xy(prev)
+		vx, vy := b.proj.xy(cur)
+		nx, ny := b.proj.xy(next)
+		inX, inY, inLen := unitVector(vx-px, vy-py)
+		outX, outY, outLen := unitVector(nx-vx, ny-vy)
+
+		// 转向角 delta，cross > 0 为左转
+		cross := inX*outY - inY*outX
+		delta := math.Acos(math.Max(-1, math.Min(1, inX*outX+inY*outY)))
+		if isRouteAnchor(cur) || inLen == 0 || outLen == 0 || delta < 1e-3 {
+			b.add(cur, i)
+			continue
+		}
+
+		maxTangent := math.Min(inLen, outLen) / 2
+		if delta > turnaroundAngle {
+			hx, hy, _ := unitVector(inX-outX, inY-outY)
+			side := 1.0 // 掉头方向，1 为逆时针（左转）
+			if cross < 0 {
+				side = -1
+			}
+			b.addTurnaround(vx, vy, hx, hy, side, (math.Pi-delta)/2, math.Min(radius, maxTangent), prev, cur, next, i)
+			continue
+		}
+
+		tangent := radius * math.Tan(delta/2)
+		if tangent > maxTangent {
+			tangent = maxTangent
+		}
+		r := tangent / math.Tan(delta/2)
+		side := 1.0
+		if cross < 0 {
+			side = -1
+		}
+		// 切点 A 在来路上，圆心位于 A 沿转向一侧的法线方向
+		ax, ay := vx-inX*tangent, vy-inY*tangent
+		cx, cy := ax-side*inY*r, ay+side*inX*r
+		start := math.Atan2(ay-cy, ax-cx)
+		b.addArc(cx, cy, r, start, side*delta, prev, cur, next, i)
+	}
+
+	b.add(points[len(points)-1], len(points)-2)
+	return b.points, b.source
+}
+
+// addArc 添加以 (cx, cy) 为圆心、半径 r、从角度 start 扫过 sweep 的圆弧，
+// 前半段归入来路 i-1，后半段归入去路 i
+func (b *smoothBuilder) addArc(cx, cy, r, start, sweep float64, prev, cur, next Point, i int) {
+	n := max(int(math.Ceil(math.Abs(sweep)/smoothArcStep)), 1)
+	for k := 0; k <= n; k++ {
+		angle := start + sweep*float64(k)/float64(n)
+		x, y := cx+r*math.Cos(angle), cy+r*math.Sin(angle)
+		if 2*k < n {
+			b.addXY(x, y, prev, cur, i-1)
+		} else {
+			b.addXY(x, y, cur, next, i)
+		}
+	}
+}
+
+// addTurnaround 在拐点 (vx, vy) 处添加灯泡形掉头：先向外侧沿小圆弧离开来路，再绕拐点前方的圆掉头，
+// 最后沿对称的小圆弧回到去路，三段圆弧半径均为 r，首尾相切，与来路和去路也相切
+//
+// (hx, hy) 为两条路的角平分线指向拐点前方的单位向量，beta 为来路、去路与平分线的夹角，
+// side 为 1 时向左掉头。计算在以拐点为原点、平分线为 x 轴、去路一侧为 y 轴正向的坐标系中进行。
+func (b *smoothBuilder) addTurnaround(vx, vy, hx, hy, side, beta, r float64, prev, cur, next Point, i int) {
+	ky, kx := side*hx, -side*hy
+	toWorld := func(x, y float64) (float64, float64) {
+		return vx + x*hx + y*kx, vy + x*hy + y*ky
+	}
+
+	// 来路上的切点 P1 和去路上的切点 P2 离拐点 t，两个小圆 F1、F2 关于平分线对称且恰好相接
+	t := r * math.Tan(beta/2)
+	cosB, sinB := math.Cos(beta), math.Sin(beta)
+	p1x, p1y := -t*cosB, -t*sinB
+	f1x, f1y := p1x+r*sinB, p1y-r*cosB
+	f2x, f2y := f1x, -f1y
+	p2x, p2y := p1x, -p1y
+	// 掉头圆心在平分线上，与两个小圆外切
+	cx := f1x + math.Sqrt(4*r*r-f1y*f1y)
+
+	// 小圆顺时针、掉头圆逆时针，ccw 将角度差折算到 [0, 2π)
+	ccw := func(from, to float64) float64 {
+		return math.Mod(to-from+4*math.Pi, 2*math.Pi)
+	}
+	arcs := []struct{ cx, cy, start, sweep float64 }{
+		{f1x, f1y, math.Atan2(p1y-f1y, p1x-f1x), -ccw(math.Atan2(-f1y, cx-f1x), math.Atan2(p1y-f1y, p1x-f1x))},
+		{cx, 0, math.Atan2(f1y, f1x-cx), ccw(math.Atan2(f1y, f1x-cx), math.Atan2(f2y, f2x-cx))},
+		{f2x, f2y, math.Atan2(-f2y, cx-f2x), -ccw(math.Atan2(p2y-f2y, p2x-f2x), math.Atan2(-f2y, cx-f2x))},
+	}
+
+	for a, arc := range arcs {
+		n := max(int(math.Ceil(math.Abs(arc.sweep)/smoothArcStep)), 1)
+		k := 0
+		if a > 0 {
+			// 起点与上一段圆弧的终点重合
+			k = 1
+		}
+		for ; k <= n; k++ {
+			angle := arc.start + arc.sweep*float64(k)/float64(n)
+			x, y := toWorld(arc.cx+r*math.Cos(angle), arc.cy+r*math.Sin(angle))
+			// 掉头圆的前半段归入来路，后半段归入去路
+			if a == 0 || (a == 1 && 2*k < n) {
+				b.addXY(x, y, prev, cur, i-1)
+			} else {
+				b.addXY(x, y, cur, next, i)
+			}
+		}
+	}
+}
+
+// splineRoute 用向心 Catmull-Rom 样条（alpha = 0.5）连接路线点，不会出现尖点和自交
+//
+// 首尾两段以延长的虚拟点作为控制点；带停留时间或名称的点两侧按直线处理。
+func splineRoute(points []Point) ([]Point, []int) {
+	b := smoothBuilder{proj: newLocalProjection(points)}
+	xy := make([][2]float64, len(points))
+	for i, p := range points {
+		xy[i][0], xy[i][1] = b.proj.xy(p)
+	}
+
+	for i := 0; i < len(points)-1; i++ {
+		b.add(points[i], i)
+		p1, p2 := xy[i], xy[i+1]
+		length := math.Hypot(p2[0]-p1[0], p2[1]-p1[1])
+		n := min(int(math.Ceil(length/smoothSplineStep)), smoothSplineMaxSamples)
+		if n < 2 || isRouteAnchor(points[i]) || isRouteAnchor(points[i+1]) {
+			continue
+		}
+
+		p0 := [2]float64{2*p1[0] - p2[0], 2*p1[1] - p2[1]}
+		if i > 0 {
+			p0 = xy[i-1]
+		}
+		p3 := [2]float64{2*p2[0] - p1[0], 2*p2[1] - p1[1]}
+		if i+2 < len(points) {
+			p3 = xy[i+2]
+		}
+		for k := 1; k < n; k++ {
+			x, y := catmullRom(p0, p1, p2, p3, float64(k)/float64(n))
+			b.addXY(x, y, points[i], points[i+1], i)
+		}
+	}
+	b.add(points[len(points)-1], len(points)-2)
+	return b.points, b.source
+}
+
+// catmullRom 计算向心 Catmull-Rom 样条在 p1、p2 之间参数 t（0-1）处的位置
+func catmullRom(p0, p1, p2, p3 [2]float64, t float64) (float64, float64) {
+	knot := func(a, b [2]float64) float64 {
+		// 重合的控制点会导致除零，给一个极小的间隔
+		return math.Max(math.Sqrt(math.Hypot(b[0]-a[0], b[1]-a[1])), 1e-6)
+	}
+	t0 := 0.0
+	t1 := t0 + knot(p0, p1)
+	t2 := t1 + knot(p1, p2)
+	t3 := t2 + knot(p2, p3)
+	u := t1 + (t2-t1)*t
+
+	lerp := func(a, b [2]float64, ta, tb float64) [2]float64 {
+		wa, wb := (tb-u)/(tb-ta), (u-ta)/(tb-ta)
+		return [2]float64{wa*a[0] + wb*b[0], wa*a[1] + wb*b[1]}
+	}
+	a1 := lerp(p0, p1, t0, t1)
+	a2 := lerp(p1, p2, t1, t2)
+	a3 := lerp(p2, p3, t2, t3)
+	b1 := lerp(a1, a2, t0, t2)
+	b2 := lerp(a2, a3, t1, t3)
+	c := lerp(b1, b2, t1, t2)
+	return c[0], c[1]
+}
+
+// unitVector 返回 (x, y) 方向的单位向量和原长度，零向量返回零
+func unitVector(x, y float64) (float64, float64, float64) {
+	l := math.Hypot(x, y)
+	if l == 0 {
+		return 0, 0, 0
+	}
+	return x / l, y / l, l
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+// turnRoute 向东 50 m 后按 angle 度的转向角转弯再走 50 m，正值左转
+func turnRoute(angle float64) []Point {
+	proj := newLocalProjection([]Point{{Lat: 30, Lon: 120}})
+	heading := angle * math.Pi / 180
+	v := proj.point(50, 0)
+	next := proj.point(50+50*math.Cos(heading), 50*math.Sin(heading))
+	return []Point{proj.point(0, 0), v, next}
+}
+
+func TestFilletTurnaroundTangent(t *testing.T) {
+	const radius = 5.0
+	for _, angle := range []float64{170, 180, -170} {
+		route := turnRoute(angle)
+		smoothed, source := SmoothRoute(route, SmoothingArc, radius)
+		if len(smoothed) < 10 {
+			t.Fatalf("%v°: 平滑后只有 %d 个点", angle, len(smoothed))
+		}
+		if smoothed[0] != route[0] || smoothed[len(smoothed)-1] != route[2] {
+			t.Errorf("%v°: 起点和终点应保持不变", angle)
+		}
+
+		proj := newLocalProjection(route)
+		xy := make([][2]float64, len(smoothed))
+		for i, p := range smoothed {
+			xy[i][0], xy[i][1] = proj.xy(p)
+		}
+
+		// 相邻两段的方向变化不超过圆弧的采样角度：路径连续，与来路、去路以及各段圆弧之间都相切
+		var prevX, prevY float64
+		for i := 1; i < len(xy); i++ {
+			dx, dy, length := unitVector(xy[i][0]-xy[i-1][0], xy[i][1]-xy[i-1][1])
+			if length == 0 {
+				t.Fatalf("%v°: 第 %d 个点与前一个点重合", angle, i)
+			}
+			if i > 1 {
+				turn := math.Acos(math.Max(-1, math.Min(1, prevX*dx+prevY*dy)))
+				if turn > smoothArcStep+1e-6 {
+					t.Fatalf("%v°: 第 %d 个点处方向突变 %.1f°", angle, i, turn*180/math.Pi)
+				}
+			}
+			if i > 1 && i < len(xy)-1 && length > radius {
+				t.Errorf("%v°: 掉头中第 %d 段长 %.1f m，路径不连续", angle, i, length)
+			}
+			prevX, prevY = dx, dy
+		}
+
+		// 掉头的首尾两点分别落在来路和去路上，并沿来路、去路的方向离开和进入
+		vx, vy := proj.xy(route[1])
+		legs := []struct {
+			name       string
+			ax, ay     float64 // 路上的一点
+			ux, uy     float64 // 路的前进方向
+			pi, pj, pk int     // 落在路上的点，以及与它相邻的点，方向为 pj→pk
+		}{
+			{"来路", vx, vy, 1, 0, 1, 1, 2},
+			{"去路", vx, vy, xy[len(xy)-1][0] - vx, xy[len(xy)-1][1] - vy, len(xy) - 2, len(xy) - 3, len(xy) - 2},
+		}
+		for _, leg := range legs {
+			ux, uy, _ := unitVector(leg.ux, leg.uy)
+			p := xy[leg.pi]
+			if d := math.Abs((p[0]-leg.ax)*uy - (p[1]-leg.ay)*ux); d > 1e-3 {
+				t.Errorf("%v°: 掉头在%s一端离路 %.3f m", angle, leg.name, d)
+			}
+			dx, dy, _ := unitVector(xy[leg.pk][0]-xy[leg.pj][0], xy[leg.pk][1]-xy[leg.pj][1])
+			if turn := math.Acos(math.Max(-1, math.Min(1, ux*dx+uy*dy))); turn > smoothArcStep/2+1e-6 {
+				t.Errorf("%v°: 掉头在%s一端与路的夹角 %.1f°，不相切", angle, leg.name, turn*180/math.Pi)
+			}
+		}
+
+		for i := 1; i < len(source); i++ {
+			if source[i] < source[i-1] {
+				t.Errorf("%v°: 原始路段下标倒退: %v", angle, source)
+				break
+			}
+		}
+	}
+}
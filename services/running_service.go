@@ -2,19 +2,23 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
 	"sync"
 	"time"
-
-	"github.com/wailsapp/wails/v3/pkg/application"
 )
 
 // Point 路线点
 type Point struct {
-	Lat float64 `json:"lat"`
-	Lon float64 `json:"lon"`
+	Lat   float64   `json:"lat"`
+	Lon   float64   `json:"lon"`
+	Ele   float64   `json:"ele,omitempty"`   // 海拔 m，可选
+	Time  time.Time `json:"time,omitzero"`   // 时间戳，可选
+	Speed float64   `json:"speed,omitempty"` // 速度 km/h，可选
+	Dwell float64   `json:"dwell,omitempty"` // 到达后停留时间 s，可选
+	Label string    `json:"label,omitempty"` // 航点名称，可选
 }
 
 // RunningState 跑步状态
@@ -26,64 +30,144 @@ const (
 	StatePaused  RunningState = "paused"
 )
 
+// RunGoal 跑步结束目标，与循环圈数相互独立，先达到哪一项就在哪一项结束
+type RunGoal struct {
+	Distance float64 `json:"distance,omitempty"` // km，可在一圈中途结束，0 表示不限
+	Duration float64 `json:"duration,omitempty"` // s，按跑步时间计算，不含暂停，0 表示不限
+}
+
+// Validate 检查结束目标
+func (g RunGoal) Validate() error {
+	if g.Distance < 0 || g.Duration < 0 {
+		return fmt.Errorf("目标距离和时间不能为负数")
+	}
+	return nil
+}
+
+func (g RunGoal) duration() time.Duration {
+	return time.Duration(g.Duration * float64(time.Second))
+}
+
+// RunEndReason 跑步结束的原因
+type RunEndReason string
+
+const (
+	EndByLoops    RunEndReason = "loops"    // 跑完全部圈数
+	EndByDistance RunEndReason = "distance" // 达到目标距离
+	EndByDuration RunEndReason = "duration" // 达到目标时间
+	EndByWorkout  RunEndReason = "workout"  // 完成间歇训练的全部阶段
+)
+
 // RunningStatus 跑步状态信息
 type RunningStatus struct {
-	State         RunningState `json:"state"`
-	CurrentIndex  int          `json:"currentIndex"`
-	TotalPoints   int          `json:"totalPoints"`
-	CurrentLat    float64      `json:"currentLat"`
-	CurrentLon    float64      `json:"currentLon"`
-	Speed         float64      `json:"speed"`
-	Distance      float64      `json:"distance"`
-	ElapsedTimeMs int64        `json:"elapsedTimeMs"`
-	Progress      float64      `json:"progress"`    // 当前段内的进度 0-1
-	LoopCount     int          `json:"loopCount"`   // 循环次数
-	CurrentLoop   int          `json:"currentLoop"` // 当前圈数
+	State         RunningState    `json:"state"`
+	CurrentIndex  int             `json:"currentIndex"`
+	TotalPoints   int             `json:"totalPoints"`
+	CurrentLat    float64         `json:"currentLat"`
+	CurrentLon    float64         `json:"currentLon"`
+	Speed         float64         `json:"speed"`
+	Distance      float64         `json:"distance"`
+	ElapsedTimeMs int64           `json:"elapsedTimeMs"`
+	Progress      float64         `json:"progress"`              // 当前段内的进度 0-1
+	LoopCount     int             `json:"loopCount"`             // 循环次数
+	CurrentLoop   int             `json:"currentLoop"`           // 当前圈数
+	Seed          int64           `json:"seed"`                  // 本次跑步的随机种子
+	Interval      *IntervalStatus `json:"interval,omitempty"`    // 当前训练阶段，未设置训练时为空
+	TargetMs      int64           `json:"targetMs,omitempty"`    // 目标完成时间，从开始跑步算起，含暂停
+	Goal          *RunGoal        `json:"goal,omitempty"`        // 结束目标，未设置时为空
+	CompletedBy   RunEndReason    `json:"completedBy,omitempty"` // 跑步结束的原因，随 running:completed 发送
+}
+
+// TrackPoint 跑步过程中实际下发到设备的位置
+type TrackPoint struct {
+	Lat      float64   `json:"lat"`
+	Lon      float64   `json:"lon"`
+	Time     time.Time `json:"time"`
+	Speed    float64   `json:"speed"`    // km/h
+	Distance float64   `json:"distance"` // 累计距离 km
+	Lap      int       `json:"lap"`      // 所在圈数，从 1 开始
 }
 
+// trackInterval 轨迹记录的最小间隔
+const trackInterval = time.Second
+
 // RunningService 跑步模拟服务
 type RunningService struct {
-	mu              sync.Mutex
-	runWG           sync.WaitGroup
-	state           RunningState
-	route           []Point
-	currentIndex    int
-	speed           float64       // 目标速度 km/h
-	currentSpeed    float64       // 当前实时速度 km/h
-	speedVariance   float64       // 速度变化范围 km/h
-	routeOffset     float64       // 路线偏移距离
-	loopCount       int           // 循环次数 0=无限
-	updateInterval  time.Duration // 位置更新间隔
-	udid            string
-	cancel          context.CancelFunc
-	locationService *LocationService
-	distance        float64
-	startTime       time.Time
-	pausedDuration  time.Duration
-	lastPauseTime   time.Time
-	progress        float64 // 当前段内的进度 0-1
-	currentLoop     int     // 当前圈数
-}
-
-// NewRunningService 创建跑步服务
-func NewRunningService(locationService *LocationService) *RunningService {
-	if locationService == nil {
-		locationService = &LocationService{}
+	mu             sync.Mutex
+	runWG          sync.WaitGroup
+	state          RunningState
+	route          []Point
+	currentIndex   int
+	speed          float64 // 目标速度 km/h
+	baseSpeed      float64 // 开始跑步时的速度，路线点自带速度按 speed/baseSpeed 缩放
+	currentSpeed   float64 // 当前实时速度 km/h
+	speedVariance  float64 // 速度变化范围 km/h
+	routeOffset    float64 // 横向偏移幅度 m
+	offsetBias     float64 // 固定横向偏移 m，正值偏向前进方向右侧
+	noise          GPSNoiseConfig
+	workout        *Workout
+	targetFinish   time.Duration // 目标完成时间，0 表示按速度跑
+	goal           RunGoal
+	profile        MotionProfile   // 加减速与过弯模型
+	completedBy    RunEndReason    // 上一次跑步结束的原因
+	interval       *IntervalStatus // 当前训练阶段
+	seed           int64           // 指定的随机种子，0 表示每次随机生成
+	runSeed        int64           // 本次跑步实际使用的随机种子
+	clock          Clock
+	loopCount      int           // 循环次数 0=无限
+	coordSystem    CoordSystem   // 传入路线的坐标系
+	smoothing      SmoothingMode // 轨迹平滑方式
+	turnRadius     float64       // 转弯半径 m
+	sourceIndex    []int         // 平滑后每个路线点对应的原始路段
+	routePoints    int           // 原始路线点数
+	updateInterval time.Duration // 位置更新间隔
+	udid           string
+	cancel         context.CancelFunc
+	location       LocationBackend
+	events         EventSink
+	distance       float64
+	startTime      time.Time
+	pausedDuration time.Duration
+	lastPauseTime  time.Time
+	pauseBraking   bool    // 已暂停但仍在减速，停下后才开始计入暂停时间
+	progress       float64 // 当前段内的进度 0-1
+	currentLoop    int     // 当前圈数
+	track          []TrackPoint
+}
+
+// NewRunningService 创建跑步服务，location 为位置下发后端，传 nil 时按设备版本自动选择；
+// 跑步过程中的位置、完成和错误事件发送到 events
+func NewRunningService(location LocationBackend, events EventSink) *RunningService {
+	return NewRunningServiceWithClock(location, events, realClock{})
+}
+
+// NewRunningServiceWithClock 创建使用指定时钟的跑步服务，配合 ManualClock 和 SetSeed 可逐位复现一次跑步
+func NewRunningServiceWithClock(location LocationBackend, events EventSink, clock Clock) *RunningService {
+	if location == nil {
+		location = NewLocationService()
+	}
+	if events == nil {
+		events = discardEvents{}
 	}
 
 	return &RunningService{
-		state:           StateIdle,
-		speed:           8.0,
-		currentSpeed:    8.0,
-		speedVariance:   1.0,
-		routeOffset:     3.0,
-		updateInterval:  100 * time.Millisecond,
-		loopCount:       1,
-		locationService: locationService,
+		state:          StateIdle,
+		speed:          8.0,
+		currentSpeed:   8.0,
+		speedVariance:  1.0,
+		routeOffset:    3.0,
+		updateInterval: 100 * time.Millisecond,
+		loopCount:      1,
+		coordSystem:    CoordWGS84,
+		smoothing:      SmoothingNone,
+		turnRadius:     defaultTurnRadius,
+		location:       location,
+		events:         events,
+		clock:          clock,
 	}
 }
 
-// StartRun 开始跑步
+// StartRun 开始跑步，speed 为没有自带速度的路段使用的速度
 func (r *RunningService) StartRun(udid string, route []Point, speed float64) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -96,24 +180,54 @@ func (r *RunningService) StartRun(udid string, route []Point, speed float64) err
 		return fmt.Errorf("路线点数量不足，至少需要 2 个点")
 	}
 
-	if speed <= 0 {
+	if speed <= 0 && r.targetFinish == 0 {
 		return fmt.Errorf("速度必须大于 0")
 	}
 
-	routeCopy := append([]Point(nil), route...)
+	// 统一转换为设备使用的 WGS84
+	routeCopy := TransformPoints(route, r.coordSystem, CoordWGS84)
+	routeCopy, source := SmoothRoute(routeCopy, r.smoothing, r.turnRadius)
 
-	Log.Info("RunningService", fmt.Sprintf("为设备 %s 开始跑步，%d 个路线点，速度 %.2f km/h", udid, len(route), speed))
+	if r.targetFinish > 0 {
+		var err error
+		if speed, err = targetPace(routeCopy, r.loopCount, r.goal.Distance, r.targetFinish, r.workout); err != nil {
+			return err
+		}
+		Log.Info("RunningService", fmt.Sprintf("目标完成时间 %s，计划平均速度 %.2f km/h", r.targetFinish, speed))
+	}
+
+	seed := r.seed
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+
+	Log.Info("RunningService", fmt.Sprintf("为设备 %s 开始跑步，%d 个路线点，速度 %.2f km/h，随机种子 %d", udid, len(route), speed, seed))
 	r.udid = udid
 	r.route = routeCopy
+	r.sourceIndex = source
+	r.routePoints = len(route)
 	r.speed = speed
+	r.baseSpeed = speed
 	r.currentSpeed = speed
 	r.currentIndex = 0
 	r.state = StateRunning
 	r.distance = 0
 	r.currentLoop = 1
 	r.progress = 0
-	r.startTime = time.Now()
+	r.startTime = r.clock.Now()
+	r.runSeed = seed
+	r.interval = nil
+	r.completedBy = ""
 	r.pausedDuration = 0
+	r.pauseBraking = false
+	r.track = nil
+
+	if r.updateInterval <= 0 {
+		r.updateInterval = 100 * time.Millisecond
+		Log.Warn("RunningService", fmt.Sprintf("检测到无效更新间隔，使用默认值: %s", r.updateInterval))
+	}
+	// 定时器在返回前创建，手动时钟随后推进的时间不会被遗漏
+	ticker := r.clock.NewTicker(r.updateInterval)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	r.cancel = cancel
@@ -121,7 +235,7 @@ func (r *RunningService) StartRun(udid string, route []Point, speed float64) err
 	r.runWG.Add(1)
 	go func() {
 		defer r.runWG.Done()
-		r.runLoop(ctx)
+		r.runLoop(ctx, ticker)
 	}()
 
 	return nil
@@ -135,7 +249,12 @@ func (r *RunningService) PauseRun() {
 	if r.state == StateRunning {
 		Log.Info("RunningService", "暂停跑步")
 		r.state = StatePaused
-		r.lastPauseTime = time.Now()
+		if r.profile.enabled() && r.currentSpeed > 0 {
+			// 减速过程仍在移动，计入跑步时间，与距离和目标时间保持一致
+			r.pauseBraking = true
+		} else {
+			r.lastPauseTime = r.clock.Now()
+		}
 	}
 }
 
@@ -147,7 +266,12 @@ func (r *RunningService) ResumeRun() {
 	if r.state == StatePaused {
 		Log.Info("RunningService", "恢复跑步")
 		r.state = StateRunning
-		r.pausedDuration += time.Since(r.lastPauseTime)
+		if r.pauseBraking {
+			// 还没停下就恢复，没有需要扣除的暂停时间
+			r.pauseBraking = false
+		} else {
+			r.pausedDuration += r.clock.Now().Sub(r.lastPauseTime)
+		}
 	}
 }
 
@@ -158,7 +282,7 @@ func (r *RunningService) StopRun() {
 	cancel := r.cancel
 	r.cancel = nil
 	udid := r.udid
-	locationSvc := r.locationService
+	location := r.location
 	r.state = StateIdle
 	r.currentIndex = 0
 	r.progress = 0
@@ -173,12 +297,12 @@ func (r *RunningService) StopRun() {
 	// 等待 runLoop 完全退出
 	r.runWG.Wait()
 
-	if udid != "" && locationSvc != nil {
-		_ = locationSvc.ResetLocation(udid)
+	if udid != "" && location != nil {
+		_ = location.ResetLocation(udid)
 	}
 }
 
-// SetSpeed 设置速度
+// SetSpeed 设置速度；路线点自带速度时，按新速度与开始跑步时速度之比整体缩放
 func (r *RunningService) SetSpeed(speed float64) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -189,7 +313,8 @@ func (r *RunningService) SetSpeed(speed float64) {
 	r.currentSpeed = speed
 }
 
-// SetRandomization 设置随机化参数
+// SetRandomization 设置随机化参数，speedVariance 为速度变化范围 km/h，
+// routeOffset 为垂直于前进方向的随机横向偏移幅度 m
 func (r *RunningService) SetRandomization(speedVariance, routeOffset float64) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -203,6 +328,87 @@ func (r *RunningService) SetRandomization(speedVariance, routeOffset float64) {
 	r.routeOffset = routeOffset
 }
 
+// SetOffsetBias 设置固定的横向偏移 m，模拟始终靠道路一侧跑，正值偏向前进方向右侧，负值偏向左侧
+func (r *RunningService) SetOffsetBias(meters float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.offsetBias = meters
+}
+
+// SetGPSNoise 设置之后开始的跑步叠加的 GPS 噪声，传入空配置则只保留横向偏移
+func (r *RunningService) SetGPSNoise(cfg GPSNoiseConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.noise = GPSNoiseConfig{Layers: append([]NoiseLayer(nil), cfg.Layers...)}
+	return nil
+}
+
+// SetSeed 设置之后开始的跑步使用的随机种子，0 表示每次随机生成；
+// 实际使用的种子在 running:started 事件和 GetStatus 中返回，传回即可复现同一次跑步
+func (r *RunningService) SetSeed(seed int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seed = seed
+}
+
+// SetWorkout 设置之后开始的跑步执行的间歇训练，传 nil 取消训练
+func (r *RunningService) SetWorkout(workout *Workout) error {
+	if workout != nil {
+		if err := workout.Validate(); err != nil {
+			return err
+		}
+		w := *workout
+		workout = &w
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workout = workout
+	return nil
+}
+
+// SetTargetFinish 设置之后开始的跑步的目标完成时间，从开始跑步算起，含暂停时间；传 0 取消
+//
+// 设置后 StartRun 传入的速度不再使用，按路线长度乘以圈数计算配速，跑步中持续修正，
+// 速度波动、暂停和停留造成的偏差在剩余路程中追回。需要指定循环圈数，不能与间歇训练同时使用。
+func (r *RunningService) SetTargetFinish(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("目标完成时间不能为负数")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.targetFinish = d
+	return nil
+}
+
+// SetRunGoal 设置距离和时间结束目标，跑步中修改立即生效；与循环圈数同时设置时先达到的一项结束跑步，
+// 只按目标结束时将循环圈数设为 0
+func (r *RunningService) SetRunGoal(goal RunGoal) error {
+	if err := goal.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.goal = goal
+	return nil
+}
+
+// SetMotionProfile 设置加减速与过弯模型，跑步中修改立即生效；传零值恢复为速度瞬间变化
+//
+// 开始跑步和恢复跑步时从 0 按加速度起步，拐角、停留点和最后一圈的终点前按减速度提前减速，
+// 暂停后先减速停下再保持不动。预设见 MotionProfilePreset。
+func (r *RunningService) SetMotionProfile(profile MotionProfile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.profile = profile
+	return nil
+}
+
 // SetLoopCount 设置循环圈数
 func (r *RunningService) SetLoopCount(count int) {
 	r.mu.Lock()
@@ -212,6 +418,37 @@ func (r *RunningService) SetLoopCount(count int) {
 	}
 }
 
+// SetCoordSystem 设置之后传入 StartRun 的路线所用坐标系，默认为 WGS84
+func (r *RunningService) SetCoordSystem(cs CoordSystem) error {
+	parsed, err := ParseCoordSystem(string(cs))
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.coordSystem = parsed
+	return nil
+}
+
+// SetSmoothing 设置之后开始的跑步的轨迹平滑方式，turnRadius 为圆角模式的转弯半径（米），0 为默认值
+func (r *RunningService) SetSmoothing(mode SmoothingMode, turnRadius float64) error {
+	parsed, err := ParseSmoothingMode(string(mode))
+	if err != nil {
+		return err
+	}
+	if turnRadius < 0 {
+		return fmt.Errorf("转弯半径不能为负数")
+	}
+	if turnRadius == 0 {
+		turnRadius = defaultTurnRadius
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.smoothing = parsed
+	r.turnRadius = turnRadius
+	return nil
+}
+
 // GetStatus 获取当前状态
 func (r *RunningService) GetStatus() RunningStatus {
 	r.mu.Lock()
@@ -233,16 +470,13 @@ func (r *RunningService) GetStatus() RunningStatus {
 
 	var elapsed time.Duration
 	if r.state != StateIdle {
-		elapsed = time.Since(r.startTime) - r.pausedDuration
-		if r.state == StatePaused {
-			elapsed -= time.Since(r.lastPauseTime)
-		}
+		elapsed = r.runningTime(r.clock.Now())
 	}
 
 	return RunningStatus{
 		State:         r.state,
-		CurrentIndex:  r.currentIndex,
-		TotalPoints:   len(r.route),
+		CurrentIndex:  sourceRouteIndex(r.sourceIndex, r.currentIndex, r.routePoints),
+		TotalPoints:   r.routePoints,
 		CurrentLat:    currentLat,
 		CurrentLon:    currentLon,
 		Speed:         r.currentSpeed,
@@ -251,229 +485,287 @@ func (r *RunningService) GetStatus() RunningStatus {
 		Progress:      r.progress,
 		LoopCount:     r.loopCount,
 		CurrentLoop:   r.currentLoop,
+		Seed:          r.runSeed,
+		Interval:      r.interval,
+		TargetMs:      r.targetFinish.Milliseconds(),
+		Goal:          r.goalStatus(),
+		CompletedBy:   r.completedBy,
 	}
 }
 
-// runLoop 跑步循环 - 更精确的速度控制
-func (r *RunningService) runLoop(ctx context.Context) {
-	r.mu.Lock()
-	interval := r.updateInterval
-	if interval <= 0 {
-		interval = 100 * time.Millisecond
-		r.updateInterval = interval
-		Log.Warn("RunningService", fmt.Sprintf("检测到无效更新间隔，使用默认值: %s", interval))
+// runningTime 开始跑步至 now 的跑步时间，不含暂停，暂停后减速的过程计入跑步时间；调用方需持有锁
+func (r *RunningService) runningTime(now time.Time) time.Duration {
+	elapsed := now.Sub(r.startTime) - r.pausedDuration
+	if r.state == StatePaused && !r.pauseBraking {
+		elapsed -= now.Sub(r.lastPauseTime)
 	}
-	r.mu.Unlock()
-
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	pointIndex := 0
-	currentLoop := 1
-	var totalDistanceKM float64
-	lastLogTime := time.Now()
-	lastStepTime := time.Now()
-	progress := 0.0
+	return elapsed
+}
 
-	var offsetLat, offsetLon float64
-	lastOffsetUpdateTime := time.Now()
+// pauseStopped 暂停后已减速停下，从 now 开始计入暂停时间；调用方需持有锁
+func (r *RunningService) pauseStopped(now time.Time) {
+	if r.state == StatePaused && r.pauseBraking {
+		r.pauseBraking = false
+		r.lastPauseTime = now
+	}
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			now := time.Now()
-			stepDuration := now.Sub(lastStepTime)
-			lastStepTime = now
+// goalStatus 状态中的结束目标，调用方需持有锁
+func (r *RunningService) goalStatus() *RunGoal {
+	if r.goal == (RunGoal{}) {
+		return nil
+	}
+	goal := r.goal
+	return &goal
+}
 
-			r.mu.Lock()
-			state := r.state
-			route := r.route
-			baseSpeed := r.speed
-			speedVariance := r.speedVariance
-			routeOffset := r.routeOffset
-			loopCount := r.loopCount
-			udid := r.udid
-			startTime := r.startTime
-			pausedDuration := r.pausedDuration
-			lastPauseTime := r.lastPauseTime
-			locationSvc := r.locationService
-			r.mu.Unlock()
+// GetTrack 获取最近一次跑步实际下发的轨迹，停止后仍可获取，直到下一次开始跑步
+func (r *RunningService) GetTrack() []TrackPoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]TrackPoint(nil), r.track...)
+}
 
-			if state == StatePaused {
-				lastStepTime = time.Now()
-				continue
-			}
+// runLoop 跑步循环 - 更精确的速度控制
+func (r *RunningService) runLoop(ctx context.Context, ticker Ticker) {
+	defer ticker.Stop()
 
-			if state != StateRunning {
-				return
-			}
+	r.mu.Lock()
+	route := r.route
+	sourceIndex := r.sourceIndex
+	routePoints := r.routePoints
+	noiseConfig := r.noise
+	seed := r.runSeed
+	started := r.startTime
+	workout := r.workout
+	r.mu.Unlock()
 
-			if len(route) < 2 {
-				Log.Error("RunningService", "路线点不足，终止跑步")
+	// 随机量全部来自本次跑步的种子，时间全部来自定时器，相同的种子和时钟得到相同的轨迹
+	motion := newMotionState(route, noiseConfig, seed, workout)
+
+	r.events.Emit(EventRunningStarted, r.GetStatus())
+	r.emitInterval(motion.interval())
+
+	lastLogTime := started
+	lastStepTime := started
+
+	// tick 处理一次定时器触发，返回 false 时结束跑步
+	tick := func(now time.Time) bool {
+		// 使用定时器触发的时刻而非读取时钟，手动时钟下每一步的时间都是确定的
+		stepDuration := now.Sub(lastStepTime)
+		lastStepTime = now
+
+		r.mu.Lock()
+		state := r.state
+		params := r.motionParams()
+		goal := r.goalStatus()
+		udid := r.udid
+		startTime := r.startTime
+		location := r.location
+		r.mu.Unlock()
+
+		if state == StatePaused {
+			// 设置了加减速模型时先减速停下，再保持不动
+			if !params.profile.enabled() || motion.velocity <= 0 {
 				r.mu.Lock()
-				r.state = StateIdle
+				r.pauseStopped(now)
 				r.mu.Unlock()
-				application.Get().Event.Emit("running:error", "路线点不足，至少需要 2 个点")
-				return
+				return true
 			}
+			params.stopping = true
+		} else if state != StateRunning {
+			return false
+		}
 
-			// 检查是否完成
-			if pointIndex >= len(route)-1 {
-				if loopCount > 0 && currentLoop >= loopCount {
-					r.mu.Lock()
-					r.state = StateIdle
-					r.distance = totalDistanceKM
-					r.currentLoop = currentLoop
-					r.currentIndex = len(route) - 1
-					r.progress = 1
-					r.mu.Unlock()
-					Log.Info("RunningService", fmt.Sprintf("跑步完成！总距离: %.0fm, 圈数: %d", totalDistanceKM*1000, currentLoop))
-					application.Get().Event.Emit("running:completed", r.GetStatus())
-					return
-				}
-				// 开始新的循环
-				pointIndex = 0
-				progress = 0
-				currentLoop++
-				Log.Info("RunningService", fmt.Sprintf("开始第 %d 圈", currentLoop))
-			}
+		if len(route) < 2 {
+			Log.Error("RunningService", "路线点不足，终止跑步")
+			r.mu.Lock()
+			r.state = StateIdle
+			r.mu.Unlock()
+			r.events.Emit(EventRunningError, "路线点不足，至少需要 2 个点")
+			return false
+		}
 
-			currentSpeed := baseSpeed
-			if speedVariance > 0 {
-				// 使用正弦函数实现速度的平滑波动
-				elapsed := time.Since(startTime).Seconds()
-				variation := math.Sin(elapsed*0.5) * speedVariance * 0.5
-				currentSpeed += variation
-				if currentSpeed < 0.5 {
-					currentSpeed = 0.5
-				}
-			}
+		lastLoop := motion.currentLoop
+		lastInterval := motion.interval()
+		status := motion.step(now.Sub(startTime), stepDuration, params)
+		for loop := lastLoop + 1; loop <= motion.currentLoop; loop++ {
+			Log.Info("RunningService", fmt.Sprintf("开始第 %d 圈", loop))
+		}
+		if interval := motion.interval(); interval != nil && (lastInterval == nil || interval.Index != lastInterval.Index) {
+			r.emitInterval(interval)
+		}
 
-			// 按真实经过时间推进，避免设备位置注入耗时导致实际速度偏慢。
-			speedKMMS := currentSpeed / (3600 * 1000)
-			remainingMoveKM := speedKMMS * float64(stepDuration.Milliseconds())
-
-			// 按距离推进进度，避免重复累计导致距离异常
-			for remainingMoveKM > 0 {
-				if pointIndex >= len(route)-1 {
-					break
-				}
-
-				startPoint := route[pointIndex]
-				endPoint := route[pointIndex+1]
-				segmentDistanceKM := haversine(startPoint.Lat, startPoint.Lon, endPoint.Lat, endPoint.Lon)
-
-				if segmentDistanceKM < 0.0001 {
-					pointIndex++
-					progress = 0
-					continue
-				}
-
-				remainingInSegmentKM := segmentDistanceKM * (1 - progress)
-				if remainingMoveKM < remainingInSegmentKM {
-					progress += remainingMoveKM / segmentDistanceKM
-					totalDistanceKM += remainingMoveKM
-					remainingMoveKM = 0
-					continue
-				}
-
-				totalDistanceKM += remainingInSegmentKM
-				remainingMoveKM -= remainingInSegmentKM
-				pointIndex++
-				progress = 0
-
-				if pointIndex >= len(route)-1 {
-					if loopCount > 0 && currentLoop >= loopCount {
-						break
-					}
-					pointIndex = 0
-					currentLoop++
-					Log.Info("RunningService", fmt.Sprintf("开始第 %d 圈", currentLoop))
-				}
-			}
+		switch status {
+		case motionFinished:
+			r.mu.Lock()
+			r.state = StateIdle
+			r.distance = motion.distanceKM
+			r.currentLoop = motion.currentLoop
+			r.currentIndex = motion.pointIndex
+			r.progress = motion.progress
+			r.interval = nil
+			r.completedBy = motion.endReason
+			r.mu.Unlock()
+			Log.Info("RunningService", fmt.Sprintf("跑步完成（%s）！总距离: %.0fm, 圈数: %d", motion.endReason, motion.distanceKM*1000, motion.currentLoop))
+			r.events.Emit(EventRunningCompleted, r.GetStatus())
+			return false
+		case motionHolding:
+			return true
+		}
 
-			// 检查是否到达终点
-			if pointIndex >= len(route)-1 {
-				continue
+		currentPoint := Point{
+			Lat: motion.lat,
+			Lon: motion.lon,
+		}
+		currentSpeed := motion.speed
+		totalDistanceKM := motion.distanceKM
+		currentLoop := motion.currentLoop
+		pointIndex := motion.pointIndex
+		progress := motion.progress
+
+		// 设置位置
+		sent := false
+		if location != nil {
+			err := location.SetLocation(udid, currentPoint.Lat, currentPoint.Lon)
+			if errors.Is(err, ErrDaemonUnavailable) {
+				// 守护进程已退出，位置会话随之结束，继续跑步也无法下发位置
+				Log.Error("RunningService", fmt.Sprintf("守护进程已断开，终止跑步: %v", err))
+				r.mu.Lock()
+				r.state = StateIdle
+				r.mu.Unlock()
+				r.events.Emit(EventRunningAborted, err.Error())
+				return false
+			} else if err != nil {
+				Log.Error("RunningService", fmt.Sprintf("设置位置失败: %v", err))
+				r.events.Emit(EventRunningError, err.Error())
+			} else {
+				sent = true
 			}
+		}
 
-			startPoint := route[pointIndex]
-			endPoint := route[pointIndex+1]
-
-			// 在两点之间进行线性插值计算当前位置
-			currentLat := startPoint.Lat + (endPoint.Lat-startPoint.Lat)*progress
-			currentLon := startPoint.Lon + (endPoint.Lon-startPoint.Lon)*progress
-
-			// 路线偏移：使用缓慢变化的偏移量，而不是每次随机
-			if routeOffset > 0 {
-				// 每3秒缓慢更新一次目标偏移量
-				if time.Since(lastOffsetUpdateTime) > 3*time.Second {
-					targetOffsetLat := (rand.Float64()*2 - 1) * routeOffset * 0.00001
-					targetOffsetLon := (rand.Float64()*2 - 1) * routeOffset * 0.00001
-					// 平滑过渡到新偏移
-					offsetLat = offsetLat*0.7 + targetOffsetLat*0.3
-					offsetLon = offsetLon*0.7 + targetOffsetLon*0.3
-					lastOffsetUpdateTime = time.Now()
-				}
-				currentLat += offsetLat
-				currentLon += offsetLon
-			}
+		// 更新统计信息
+		r.mu.Lock()
+		if sent && (len(r.track) == 0 || now.Sub(r.track[len(r.track)-1].Time) >= trackInterval) {
+			r.track = append(r.track, TrackPoint{
+				Lat:      currentPoint.Lat,
+				Lon:      currentPoint.Lon,
+				Time:     now,
+				Speed:    currentSpeed,
+				Distance: totalDistanceKM,
+				Lap:      currentLoop,
+			})
+		}
+		r.currentIndex = pointIndex
+		r.currentLoop = currentLoop
+		r.distance = totalDistanceKM
+		r.currentSpeed = currentSpeed
+		r.progress = progress
+		if params.stopping && motion.velocity <= 0 {
+			r.pauseStopped(now)
+		}
+		// 计算经过的时间
+		var elapsed time.Duration
+		if r.state != StateIdle {
+			elapsed = r.runningTime(now)
+		}
+		r.mu.Unlock()
+
+		r.events.Emit(EventRunningPosition, RunningStatus{
+			State:         state,
+			CurrentLat:    currentPoint.Lat,
+			CurrentLon:    currentPoint.Lon,
+			Speed:         currentSpeed,
+			Distance:      totalDistanceKM,
+			CurrentLoop:   currentLoop,
+			CurrentIndex:  sourceRouteIndex(sourceIndex, pointIndex, routePoints),
+			TotalPoints:   routePoints,
+			ElapsedTimeMs: elapsed.Milliseconds(),
+			Progress:      progress,
+			Interval:      motion.interval(),
+			TargetMs:      params.targetFinish.Milliseconds(),
+			Goal:          goal,
+		})
+
+		// 每10秒输出一次状态日志
+		if now.Sub(lastLogTime) >= 10*time.Second {
+			Log.Debug("RunningService", fmt.Sprintf("跑步中：距离=%.0fm，速度=%.1fkm/h，位置=(%.5f, %.5f)，圈数=%d/%d",
+				totalDistanceKM*1000, currentSpeed, currentPoint.Lat, currentPoint.Lon, currentLoop, params.loopCount))
+			lastLogTime = now
+		}
+		return true
+	}
 
-			currentPoint := Point{
-				Lat: currentLat,
-				Lon: currentLon,
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C():
+			running := tick(now)
+			ticker.Done()
+			if !running {
+				return
 			}
+		}
+	}
+}
 
-			// 设置位置
-			if locationSvc != nil {
-				err := locationSvc.SetLocation(udid, currentPoint.Lat, currentPoint.Lon)
-				if err != nil {
-					Log.Error("RunningService", fmt.Sprintf("设置位置失败: %v", err))
-					application.Get().Event.Emit("running:error", err.Error())
-				}
-			}
+// emitInterval 记录并发送新的训练阶段
+func (r *RunningService) emitInterval(interval *IntervalStatus) {
+	if interval == nil {
+		return
+	}
+	r.mu.Lock()
+	r.interval = interval
+	r.mu.Unlock()
 
-			// 更新统计信息
-			r.mu.Lock()
-			r.currentIndex = pointIndex
-			r.currentLoop = currentLoop
-			r.distance = totalDistanceKM
-			r.currentSpeed = currentSpeed
-			r.progress = progress
-			r.mu.Unlock()
+	Log.Info("RunningService", fmt.Sprintf("训练阶段 %d/%d：%s，速度 %.1f km/h", interval.Index+1, interval.Total, interval.Name(), interval.Speed))
+	r.events.Emit(EventRunningInterval, *interval)
+}
 
-			// 计算经过的时间
-			var elapsed time.Duration
-			if state != StateIdle {
-				elapsed = time.Since(startTime) - pausedDuration
-				if state == StatePaused {
-					elapsed -= time.Since(lastPauseTime)
-				}
-			}
+// motionParams 当前的运动参数，调用方需持有锁
+func (r *RunningService) motionParams() motionParams {
+	return motionParams{
+		speed:         r.speed,
+		speedScale:    r.speed / r.baseSpeed,
+		speedVariance: r.speedVariance,
+		routeOffset:   r.routeOffset,
+		offsetBias:    r.offsetBias,
+		loopCount:     r.loopCount,
+		targetFinish:  r.targetFinish,
+		goal:          r.goal,
+		profile:       r.profile,
+	}
+}
 
-			application.Get().Event.Emit("running:position", RunningStatus{
-				State:         state,
-				CurrentLat:    currentPoint.Lat,
-				CurrentLon:    currentPoint.Lon,
-				Speed:         currentSpeed,
-				Distance:      totalDistanceKM,
-				CurrentLoop:   currentLoop,
-				CurrentIndex:  pointIndex,
-				TotalPoints:   len(route),
-				ElapsedTimeMs: elapsed.Milliseconds(),
-				Progress:      progress,
-			})
+// sourceRouteIndex 将平滑后路线的下标换算为原始路线的下标
+func sourceRouteIndex(source []int, index, total int) int {
+	if len(source) == 0 {
+		return index
+	}
+	if index >= len(source)-1 {
+		return total - 1
+	}
+	return source[index]
+}
 
-			// 每10秒输出一次状态日志
-			if time.Since(lastLogTime) >= 10*time.Second {
-				Log.Debug("RunningService", fmt.Sprintf("跑步中：距离=%.0fm，速度=%.1fkm/h，位置=(%.5f, %.5f)，圈数=%d/%d",
-					totalDistanceKM*1000, currentSpeed, currentPoint.Lat, currentPoint.Lon, currentLoop, loopCount))
-				lastLogTime = time.Now()
-			}
-		}
+// metersPerDegree 每度纬度对应的米数，与 haversine 使用相同的地球半径
+const metersPerDegree = 6371000 * math.Pi / 180
+
+// offsetLocal 以 start→end 为前进方向，将位置向前偏移 along 米、向右偏移 right 米，负值反向
+func offsetLocal(lat, lon float64, start, end Point, along, right float64) (float64, float64) {
+	cosLat := math.Cos(lat * math.Pi / 180)
+	east := (end.Lon - start.Lon) * metersPerDegree * cosLat
+	north := (end.Lat - start.Lat) * metersPerDegree
+	length := math.Hypot(east, north)
+	if length == 0 || (along == 0 && right == 0) || cosLat == 0 {
+		return lat, lon
 	}
+	// 前进方向单位向量为 (ux, uy)，其右侧法线为 (uy, -ux)
+	ux, uy := east/length, north/length
+	dEast := ux*along + uy*right
+	dNorth := uy*along - ux*right
+	return lat + dNorth/metersPerDegree, lon + dEast/(metersPerDegree*cosLat)
 }
 
 // haversine 计算两点间距离
@@ -487,3 +779,12 @@ func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
 	return R * c
 }
+
+// routeDistance 计算路线总长度 km
+func routeDistance(points []Point) float64 {
+	var total float64
+	for i := 1; i < len(points); i++ {
+		total += haversine(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+	}
+	return total
+}
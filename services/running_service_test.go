@@ -0,0 +1,453 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordedEvent 跑步服务发出的一个事件
+type recordedEvent struct {
+	name string
+	data any
+}
+
+// eventRecorder 按顺序记录全部事件
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []recordedEvent
+}
+
+func (e *eventRecorder) Emit(name string, data any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, recordedEvent{name, data})
+}
+
+func (e *eventRecorder) named(name string) []any {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var out []any
+	for _, ev := range e.events {
+		if ev.name == name {
+			out = append(out, ev.data)
+		}
+	}
+	return out
+}
+
+// positions 全部 running:position 事件
+func (e *eventRecorder) positions() []RunningStatus {
+	var out []RunningStatus
+	for _, data := range e.named(EventRunningPosition) {
+		out = append(out, data.(RunningStatus))
+	}
+	return out
+}
+
+// completed 完成事件中的状态，未完成时 ok 为 false
+func (e *eventRecorder) completed() (status RunningStatus, ok bool) {
+	events := e.named(EventRunningCompleted)
+	if len(events) == 0 {
+		return RunningStatus{}, false
+	}
+	return events[0].(RunningStatus), true
+}
+
+// manualRun 用 ManualClock 驱动的一次跑步
+type manualRun struct {
+	t        *testing.T
+	clock    *ManualClock
+	service  *RunningService
+	events   *eventRecorder
+	location *MemoryLocationBackend
+}
+
+// newManualRun 创建使用手动时钟的跑步服务，默认关闭速度波动和横向偏移，便于按距离和时间断言
+func newManualRun(t *testing.T) *manualRun {
+	t.Helper()
+	NewLoggerService(nil)
+	run := &manualRun{
+		t:        t,
+		clock:    NewManualClock(time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)),
+		events:   &eventRecorder{},
+		location: NewMemoryLocationBackend(),
+	}
+	run.service = NewRunningServiceWithClock(run.location, run.events, run.clock)
+	run.service.SetRandomization(0, 0)
+	return run
+}
+
+// start 开始跑步，结束测试时停止
+func (run *manualRun) start(route []Point, speed float64) {
+	run.t.Helper()
+	if err := run.service.StartRun("test-device", route, speed); err != nil {
+		run.t.Fatalf("开始跑步失败: %v", err)
+	}
+	run.t.Cleanup(run.service.StopRun)
+}
+
+// advance 以 100 ms 为一步推进 d，跑步完成后提前返回
+func (run *manualRun) advance(d time.Duration) {
+	for elapsed := time.Duration(0); elapsed < d; elapsed += 100 * time.Millisecond {
+		if _, ok := run.events.completed(); ok {
+			return
+		}
+		run.clock.Advance(100 * time.Millisecond)
+	}
+}
+
+// finish 推进到跑步完成，最长 limit，返回完成事件中的状态和完成前最后一次位置
+func (run *manualRun) finish(limit time.Duration) (RunningStatus, RunningStatus) {
+	run.t.Helper()
+	run.advance(limit)
+	status, ok := run.events.completed()
+	if !ok {
+		run.t.Fatalf("%s 内未完成跑步", limit)
+	}
+	positions := run.events.positions()
+	if len(positions) == 0 {
+		run.t.Fatal("没有下发任何位置")
+	}
+	return status, positions[len(positions)-1]
+}
+
+// sentPoints 录制的位置下发中设置的坐标，不含重置
+func sentPoints(records []LocationRecord) []Point {
+	var points []Point
+	for _, r := range records {
+		if !r.Reset {
+			points = append(points, Point{Lat: r.Lat, Lon: r.Lon})
+		}
+	}
+	return points
+}
+
+// squareRoute 首尾相接的正方形路线，边长约 111 m
+func squareRoute() []Point {
+	return []Point{
+		{Lat: 30, Lon: 120},
+		{Lat: 30.001, Lon: 120},
+		{Lat: 30.001, Lon: 120.00115},
+		{Lat: 30, Lon: 120.00115},
+		{Lat: 30, Lon: 120},
+	}
+}
+
+func TestManualClockRunCompletion(t *testing.T) {
+	loopKM := routeDistance(squareRoute())
+
+	cases := []struct {
+		name      string
+		loops     int
+		goal      RunGoal
+		reason    RunEndReason
+		distance  float64       // 结束时的距离 km
+		elapsed   time.Duration // 结束前最后一次位置的跑步时间
+		lastLoops int
+	}{
+		{"按圈数完成", 2, RunGoal{}, EndByLoops, 2 * loopKM, time.Duration(2 * loopKM / 12 * float64(time.Hour)), 2},
+		{"目标距离在一圈中途", 0, RunGoal{Distance: 0.6}, EndByDistance, 0.6, 180 * time.Second, 2},
+		{"目标时间", 0, RunGoal{Duration: 90}, EndByDuration, 0.3, 90 * time.Second, 1},
+		{"圈数先于目标距离", 1, RunGoal{Distance: 5}, EndByLoops, loopKM, time.Duration(loopKM / 12 * float64(time.Hour)), 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			run := newManualRun(t)
+			run.service.SetLoopCount(c.loops)
+			if err := run.service.SetRunGoal(c.goal); err != nil {
+				t.Fatalf("设置目标失败: %v", err)
+			}
+			run.start(squareRoute(), 12)
+			status, last := run.finish(10 * time.Minute)
+
+			if status.CompletedBy != c.reason {
+				t.Errorf("结束原因 = %q，期望 %q", status.CompletedBy, c.reason)
+			}
+			if math.Abs(status.Distance-c.distance) > 0.001 {
+				t.Errorf("距离 = %.4f km，期望 %.4f km", status.Distance, c.distance)
+			}
+			if d := time.Duration(last.ElapsedTimeMs)*time.Millisecond - c.elapsed; d < -200*time.Millisecond || d > 0 {
+				t.Errorf("最后一次位置的跑步时间 = %d ms，期望略早于 %s", last.ElapsedTimeMs, c.elapsed)
+			}
+			if status.CurrentLoop != c.lastLoops {
+				t.Errorf("完成时圈数 = %d，期望 %d", status.CurrentLoop, c.lastLoops)
+			}
+		})
+	}
+}
+
+func TestManualClockRunLoopWrap(t *testing.T) {
+	run := newManualRun(t)
+	run.service.SetLoopCount(3)
+	route := squareRoute()
+	run.start(route, 12)
+	run.finish(10 * time.Minute)
+
+	positions := run.events.positions()
+	wraps := 0
+	for i := 1; i < len(positions); i++ {
+		prev, cur := positions[i-1], positions[i]
+		if cur.CurrentLoop < prev.CurrentLoop {
+			t.Fatalf("圈数倒退: %d -> %d", prev.CurrentLoop, cur.CurrentLoop)
+		}
+		if cur.CurrentLoop == prev.CurrentLoop {
+			if cur.Distance < prev.Distance {
+				t.Fatalf("第 %d 步距离倒退", i)
+			}
+			continue
+		}
+		wraps++
+		// 进入新的一圈后从第一段继续，位置仍连续
+		if cur.CurrentIndex != 0 {
+			t.Errorf("第 %d 圈开始于路线点 %d，期望 0", cur.CurrentLoop, cur.CurrentIndex)
+		}
+		if d := haversine(prev.CurrentLat, prev.CurrentLon, cur.CurrentLat, cur.CurrentLon); d > 0.001 {
+			t.Errorf("进入第 %d 圈时位置跳变 %.1f m", cur.CurrentLoop, d*1000)
+		}
+	}
+	if wraps != 2 {
+		t.Errorf("进入新一圈 %d 次，期望 2", wraps)
+	}
+
+	laps := map[int]bool{}
+	for _, p := range run.service.GetTrack() {
+		laps[p.Lap] = true
+	}
+	for lap := 1; lap <= 3; lap++ {
+		if !laps[lap] {
+			t.Errorf("轨迹缺少第 %d 圈", lap)
+		}
+	}
+}
+
+func TestManualClockRunSeedReplay(t *testing.T) {
+	replay := func(seed int64) []Point {
+		run := newManualRun(t)
+		run.service.SetRandomization(2, 5)
+		if err := run.service.SetGPSNoise(GPSNoiseConfig{Layers: []NoiseLayer{{Kind: NoiseGaussian, Sigma: 2}}}); err != nil {
+			t.Fatalf("设置噪声失败: %v", err)
+		}
+		run.service.SetSeed(seed)
+		run.start(squareRoute(), 10)
+		run.advance(30 * time.Second)
+		return sentPoints(run.location.Records())
+	}
+
+	// 30 秒共 300 步，Advance 返回时每一步都已下发
+	const steps = 300
+	first, second, other := replay(42), replay(42), replay(7)
+	if len(first) != steps || len(second) != steps || len(other) != steps {
+		t.Fatalf("下发位置数 = %d、%d、%d，期望 %d", len(first), len(second), len(other), steps)
+	}
+	for i := range steps {
+		if first[i] != second[i] {
+			t.Fatalf("相同种子第 %d 个位置不同: %+v != %+v", i, first[i], second[i])
+		}
+	}
+	same := true
+	for i := 0; same && i < steps; i++ {
+		same = first[i] == other[i]
+	}
+	if same {
+		t.Error("不同种子得到了相同的轨迹")
+	}
+}
+
+func TestSimulateMatchesManualClockRun(t *testing.T) {
+	run := newManualRun(t)
+	run.service.SetRandomization(2, 5)
+	run.service.SetOffsetBias(1)
+	if err := run.service.SetGPSNoise(GPSNoiseConfig{Layers: []NoiseLayer{{Kind: NoiseOU, Sigma: 2, Tau: 20}}}); err != nil {
+		t.Fatalf("设置噪声失败: %v", err)
+	}
+	if err := run.service.SetMotionProfile(motionPresets["run"]); err != nil {
+		t.Fatalf("设置运动模型失败: %v", err)
+	}
+	run.service.SetLoopCount(2)
+	run.service.SetSeed(42)
+
+	simulated, err := run.service.Simulate(squareRoute(), SimulateParams{
+		Speed:         10,
+		SpeedVariance: 2,
+		RouteOffset:   5,
+		OffsetBias:    1,
+		LoopCount:     2,
+		Seed:          42,
+	})
+	if err != nil {
+		t.Fatalf("预览失败: %v", err)
+	}
+
+	startTime := run.clock.Now()
+	run.start(squareRoute(), 10)
+	status, _ := run.finish(10 * time.Minute)
+	track := run.service.GetTrack()
+
+	// 预览首尾两点为起点和完成时刻的位置，其余逐点对应跑步记录的轨迹
+	if len(simulated) != len(track)+2 {
+		t.Fatalf("预览 %d 个点，跑步轨迹 %d 个点，期望多出起点和终点", len(simulated), len(track))
+	}
+	for i, got := range track {
+		want := simulated[i+1]
+		if got.Lat != want.Lat || got.Lon != want.Lon || got.Speed != want.Speed ||
+			got.Distance != want.Distance || got.Lap != want.Lap ||
+			got.Time.Sub(startTime).Milliseconds() != want.ElapsedTimeMs {
+			t.Fatalf("第 %d 个轨迹点 = %+v，预览为 %+v", i, got, want)
+		}
+	}
+	if last := simulated[len(simulated)-1]; last.Distance != status.Distance || last.Lap != status.CurrentLoop {
+		t.Errorf("预览结束于 %.4f km 第 %d 圈，跑步结束于 %.4f km 第 %d 圈", last.Distance, last.Lap, status.Distance, status.CurrentLoop)
+	}
+}
+
+func TestRunGoalWhicheverFirst(t *testing.T) {
+	cases := []struct {
+		name     string
+		goal     RunGoal
+		pause    time.Duration // 开始 30 秒后暂停的时长
+		reason   RunEndReason
+		distance float64
+	}{
+		// 12 km/h 下 0.3 km 需要 90 秒
+		{"距离先到", RunGoal{Distance: 0.3, Duration: 120}, 0, EndByDistance, 0.3},
+		{"时间先到", RunGoal{Distance: 0.5, Duration: 60}, 0, EndByDuration, 0.2},
+		{"暂停不计入目标时间", RunGoal{Duration: 60}, 20 * time.Second, EndByDuration, 0.2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			run := newManualRun(t)
+			run.service.SetLoopCount(0)
+			if err := run.service.SetRunGoal(c.goal); err != nil {
+				t.Fatalf("设置目标失败: %v", err)
+			}
+			run.start(squareRoute(), 12)
+			if c.pause > 0 {
+				run.advance(30 * time.Second)
+				run.service.PauseRun()
+				run.advance(c.pause)
+				run.service.ResumeRun()
+			}
+			status, _ := run.finish(10 * time.Minute)
+
+			if status.CompletedBy != c.reason {
+				t.Errorf("结束原因 = %q，期望 %q", status.CompletedBy, c.reason)
+			}
+			if math.Abs(status.Distance-c.distance) > 0.001 {
+				t.Errorf("距离 = %.4f km，期望 %.4f km", status.Distance, c.distance)
+			}
+			if status.Goal == nil || *status.Goal != c.goal {
+				t.Errorf("完成事件中的目标 = %+v，期望 %+v", status.Goal, c.goal)
+			}
+			if got := run.service.GetStatus().CompletedBy; got != c.reason {
+				t.Errorf("完成后 GetStatus 的结束原因 = %q", got)
+			}
+		})
+	}
+}
+
+func TestRunGoalChangedMidRun(t *testing.T) {
+	run := newManualRun(t)
+	run.service.SetLoopCount(0)
+	if err := run.service.SetRunGoal(RunGoal{Distance: 1}); err != nil {
+		t.Fatalf("设置目标失败: %v", err)
+	}
+	run.start(squareRoute(), 12)
+	run.advance(30 * time.Second)
+	// 跑步中缩短目标距离立即生效
+	if err := run.service.SetRunGoal(RunGoal{Distance: 0.2}); err != nil {
+		t.Fatalf("修改目标失败: %v", err)
+	}
+	status, _ := run.finish(10 * time.Minute)
+	if status.CompletedBy != EndByDistance || math.Abs(status.Distance-0.2) > 1e-9 {
+		t.Errorf("结束原因 = %q，距离 = %.4f km，期望在 0.2 km 处按距离结束", status.CompletedBy, status.Distance)
+	}
+}
+
+func TestRunGoalValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		goal RunGoal
+		ok   bool
+	}{
+		{"不限", RunGoal{}, true},
+		{"距离和时间", RunGoal{Distance: 5, Duration: 1800}, true},
+		{"距离为负", RunGoal{Distance: -1}, false},
+		{"时间为负", RunGoal{Duration: -60}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.goal.Validate(); (err == nil) != c.ok {
+				t.Errorf("Validate() = %v", err)
+			}
+		})
+	}
+}
+
+// localDisplacement 从 a 到 b 的东向、北向位移（米）
+func localDisplacement(a, b Point) (east, north float64) {
+	return (b.Lon - a.Lon) * metersPerDegree * math.Cos(a.Lat*math.Pi/180), (b.Lat - a.Lat) * metersPerDegree
+}
+
+func TestOffsetLocal(t *testing.T) {
+	origin := Point{Lat: 30, Lon: 120}
+	north := Point{Lat: 30.001, Lon: 120}
+	east := Point{Lat: 30, Lon: 120.001}
+	cases := []struct {
+		name        string
+		start, end  Point
+		along       float64
+		right       float64
+		east, north float64 // 期望位移（米）
+	}{
+		{"向北右侧", origin, north, 0, 1.5, 1.5, 0},
+		{"向北左侧", origin, north, 0, -1.5, -1.5, 0},
+		{"向东右侧", origin, east, 0, 1.5, 0, -1.5},
+		{"向东左侧", origin, east, 0, -1.5, 0, 1.5},
+		{"向南右侧", north, origin, 0, 2, -2, 0},
+		{"向西右侧", east, origin, 0, 2, 0, 2},
+		{"向东前进", origin, east, 3, 0, 3, 0},
+		{"向北前进并右偏", origin, north, -2, 1, 1, -2},
+		{"路段长度为 0", origin, origin, 3, 1.5, 0, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lat, lon := offsetLocal(origin.Lat, origin.Lon, c.start, c.end, c.along, c.right)
+			dEast, dNorth := localDisplacement(origin, Point{Lat: lat, Lon: lon})
+			if math.Abs(dEast-c.east) > 1e-6 || math.Abs(dNorth-c.north) > 1e-6 {
+				t.Errorf("位移 = (东 %.4f, 北 %.4f) m，期望 (东 %v, 北 %v) m", dEast, dNorth, c.east, c.north)
+			}
+		})
+	}
+}
+
+func TestRunOffsetBiasSide(t *testing.T) {
+	// 先向北约 111 m，再向东约 111 m
+	route := []Point{{Lat: 30, Lon: 120}, {Lat: 30.001, Lon: 120}, {Lat: 30.001, Lon: 120.00115}}
+	for _, bias := range []float64{1.5, -1.5} {
+		run := newManualRun(t)
+		run.service.SetOffsetBias(bias)
+		run.start(route, 10)
+		run.advance(75 * time.Second)
+		sent := sentPoints(run.location.Records())
+		if len(sent) != 750 {
+			t.Fatalf("偏移 %v m: 下发位置数 = %d，期望 750", bias, len(sent))
+		}
+
+		// 10 km/h 下前 30 s 在向北的路段上，偏向东侧为右；45~75 s 在向东的路段上，偏向南侧为右
+		for i, p := range sent {
+			elapsed := time.Duration(i+1) * 100 * time.Millisecond
+			switch {
+			case elapsed <= 30*time.Second:
+				if dEast, _ := localDisplacement(route[0], p); math.Abs(dEast-bias) > 1e-3 {
+					t.Fatalf("偏移 %v m: %s 向北路段上偏东 %.4f m", bias, elapsed, dEast)
+				}
+			case elapsed >= 45*time.Second:
+				if _, dNorth := localDisplacement(route[1], p); math.Abs(dNorth+bias) > 1e-3 {
+					t.Fatalf("偏移 %v m: %s 向东路段上偏北 %.4f m", bias, elapsed, dNorth)
+				}
+			}
+		}
+	}
+}
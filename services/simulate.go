@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// simulateMaxDuration 预览的默认最长时长，无限循环时在此截止
+const simulateMaxDuration = 24 * time.Hour
+
+// SimulateParams 离线预览的跑步参数，含义与 SetSpeed、SetRandomization 等设置相同
+type SimulateParams struct {
+	Speed          float64  `json:"speed"`                    // 没有自带速度的路段使用的速度 km/h
+	SpeedVariance  float64  `json:"speedVariance"`            // km/h
+	RouteOffset    float64  `json:"routeOffset"`              // m
+	OffsetBias     float64  `json:"offsetBias"`               // m
+	LoopCount      int      `json:"loopCount"`                // 0 为无限，预览到 MaxDurationSec 为止
+	Seed           int64    `json:"seed"`                     // 0 为随机
+	IntervalSec    float64  `json:"intervalSec,omitempty"`    // 输出点的时间间隔，默认 1 s
+	MaxDurationSec float64  `json:"maxDurationSec,omitempty"` // 最长预览时长，默认 24 h
+	Workout        *Workout `json:"workout,omitempty"`        // 间歇训练，设置后忽略 LoopCount
+	TargetSec      float64  `json:"targetSec,omitempty"`      // 目标完成时间 s，设置后忽略 Speed
+	Goal           RunGoal  `json:"goal"`                     // 距离和时间结束目标
+}
+
+// TimedPoint 预览轨迹上的点
+type TimedPoint struct {
+	Lat           float64 `json:"lat"`
+	Lon           float64 `json:"lon"`
+	ElapsedTimeMs int64   `json:"elapsedTimeMs"`
+	Speed         float64 `json:"speed"`    // km/h
+	Distance      float64 `json:"distance"` // 累计距离 km
+	Lap           int     `json:"lap"`      // 所在圈数，从 1 开始
+	Interval      int     `json:"interval"` // 所在训练阶段序号，未设置训练时为 0
+}
+
+// Simulate 不连接设备、不按真实时间等待，用与跑步相同的运动模型计算轨迹，用于在地图上预览路线和完成时间
+//
+// 路线坐标系、平滑方式、GPS 噪声、加减速模型和位置更新间隔使用服务当前的设置，其余参数由 params 指定；
+// 相同的 Seed 与实际跑步使用相同种子时得到相同的轨迹。最后一个点为完成时刻在路线上的位置，
+// 按目标距离结束时恰好位于目标距离处。
+func (r *RunningService) Simulate(route []Point, params SimulateParams) ([]TimedPoint, error) {
+	if len(route) < 2 {
+		return nil, fmt.Errorf("路线点数量不足，至少需要 2 个点")
+	}
+	if params.Speed <= 0 && params.TargetSec <= 0 {
+		return nil, fmt.Errorf("速度必须大于 0")
+	}
+	if params.SpeedVariance < 0 || params.RouteOffset < 0 || params.LoopCount < 0 || params.TargetSec < 0 {
+		return nil, fmt.Errorf("速度变化范围、偏移、循环圈数和目标完成时间不能为负数")
+	}
+	if err := params.Goal.Validate(); err != nil {
+		return nil, err
+	}
+	if params.Workout != nil {
+		if err := params.Workout.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	coordSystem := r.coordSystem
+	smoothing := r.smoothing
+	turnRadius := r.turnRadius
+	noise := r.noise
+	profile := r.profile
+	step := r.updateInterval
+	r.mu.Unlock()
+	if step <= 0 {
+		step = 100 * time.Millisecond
+	}
+
+	output := trackInterval
+	if params.IntervalSec > 0 {
+		output = time.Duration(params.IntervalSec * float64(time.Second))
+	}
+	maxDuration := simulateMaxDuration
+	if params.MaxDurationSec > 0 {
+		maxDuration = time.Duration(params.MaxDurationSec * float64(time.Second))
+	}
+	seed := params.Seed
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+
+	points := TransformPoints(route, coordSystem, CoordWGS84)
+	points, _ = SmoothRoute(points, smoothing, turnRadius)
+	motion := newMotionState(points, noise, seed, params.Workout)
+	target := time.Duration(params.TargetSec * float64(time.Second))
+	if target > 0 {
+		var err error
+		if params.Speed, err = targetPace(points, params.LoopCount, params.Goal.Distance, target, params.Workout); err != nil {
+			return nil, err
+		}
+	}
+	motionParams := motionParams{
+		speed:         params.Speed,
+		speedScale:    1,
+		speedVariance: params.SpeedVariance,
+		routeOffset:   params.RouteOffset,
+		offsetBias:    params.OffsetBias,
+		loopCount:     params.LoopCount,
+		targetFinish:  target,
+		goal:          params.Goal,
+		profile:       profile,
+	}
+
+	result := []TimedPoint{{Lat: points[0].Lat, Lon: points[0].Lon, Lap: 1}}
+	var elapsed, lastOutput time.Duration
+	for elapsed < maxDuration {
+		elapsed += step
+		switch motion.step(elapsed, step, motionParams) {
+		case motionFinished:
+			lat, lon := motion.position()
+			interval := 0
+			if motion.workout != nil {
+				interval = len(motion.workout.phases) - 1
+			}
+			result = append(result, TimedPoint{
+				Lat:           lat,
+				Lon:           lon,
+				ElapsedTimeMs: elapsed.Milliseconds(),
+				Distance:      motion.distanceKM,
+				Lap:           motion.currentLoop,
+				Interval:      interval,
+			})
+			return result, nil
+		case motionMoving:
+			// 与跑步轨迹的记录方式相同：第一次移动即输出，之后按间隔输出
+			if len(result) > 1 && elapsed-lastOutput < output {
+				continue
+			}
+			lastOutput = elapsed
+			result = append(result, TimedPoint{
+				Lat:           motion.lat,
+				Lon:           motion.lon,
+				ElapsedTimeMs: elapsed.Milliseconds(),
+				Speed:         motion.speed,
+				Distance:      motion.distanceKM,
+				Lap:           motion.currentLoop,
+				Interval:      intervalIndex(motion.interval()),
+			})
+		}
+	}
+	Log.Warn("RunningService", fmt.Sprintf("预览达到最长时长 %s，未跑完全部圈数", maxDuration))
+	return result, nil
+}
+
+func intervalIndex(phase *IntervalStatus) int {
+	if phase == nil {
+		return 0
+	}
+	return phase.Index
+}
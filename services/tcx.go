@@ -0,0 +1,101 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	tcxNamespace          = "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+	tcxExtensionNamespace = "http://www.garmin.com/xmlschemas/ActivityExtension/v2"
+)
+
+// tcxDatabase TCX 文档，只包含一个跑步活动
+type tcxDatabase struct {
+	XMLName    xml.Name      `xml:"TrainingCenterDatabase"`
+	Xmlns      string        `xml:"xmlns,attr"`
+	XmlnsNS3   string        `xml:"xmlns:ns3,attr"`
+	Activities []tcxActivity `xml:"Activities>Activity"`
+}
+
+type tcxActivity struct {
+	Sport string   `xml:"Sport,attr"`
+	ID    string   `xml:"Id"`
+	Laps  []tcxLap `xml:"Lap"`
+	Notes string   `xml:"Notes,omitempty"`
+}
+
+type tcxLap struct {
+	StartTime        string          `xml:"StartTime,attr"`
+	TotalTimeSeconds float64         `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64         `xml:"DistanceMeters"`
+	MaximumSpeed     float64         `xml:"MaximumSpeed"`
+	Calories         int             `xml:"Calories"`
+	Intensity        string          `xml:"Intensity"`
+	TriggerMethod    string          `xml:"TriggerMethod"`
+	Trackpoints      []tcxTrackpoint `xml:"Track>Trackpoint"`
+	AvgSpeed         float64         `xml:"Extensions>ns3:LX>ns3:AvgSpeed"`
+}
+
+type tcxTrackpoint struct {
+	Time           string  `xml:"Time"`
+	Latitude       float64 `xml:"Position>LatitudeDegrees"`
+	Longitude      float64 `xml:"Position>LongitudeDegrees"`
+	DistanceMeters float64 `xml:"DistanceMeters"`
+	Speed          float64 `xml:"Extensions>ns3:TPX>ns3:Speed"`
+}
+
+// WriteTrackTCX 将跑步轨迹写为 TCX 活动，每圈对应一个 Lap，name 写入活动备注
+//
+// 计时时间扣除暂停，速度单位为 m/s，均取自实际下发到设备的轨迹点。
+func WriteTrackTCX(w io.Writer, name string, track []TrackPoint) error {
+	if len(track) == 0 {
+		return fmt.Errorf("轨迹为空，无法导出")
+	}
+
+	activity := tcxActivity{
+		Sport: "Running",
+		ID:    track[0].Time.UTC().Format(time.RFC3339),
+		Notes: name,
+	}
+	for _, lap := range splitTrackLaps(track) {
+		tl := tcxLap{
+			StartTime:        lap.Start.UTC().Format(time.RFC3339),
+			TotalTimeSeconds: lap.Timer.Seconds(),
+			DistanceMeters:   lap.distance(),
+			MaximumSpeed:     lap.maxSpeed(),
+			Intensity:        "Active",
+			TriggerMethod:    "Manual",
+			AvgSpeed:         lap.avgSpeed(),
+		}
+		for _, p := range lap.Points {
+			tl.Trackpoints = append(tl.Trackpoints, tcxTrackpoint{
+				Time:           p.Time.UTC().Format(time.RFC3339Nano),
+				Latitude:       p.Lat,
+				Longitude:      p.Lon,
+				DistanceMeters: p.Distance * 1000,
+				Speed:          p.Speed / 3.6,
+			})
+		}
+		activity.Laps = append(activity.Laps, tl)
+	}
+
+	doc := tcxDatabase{
+		Xmlns:      tcxNamespace,
+		XmlnsNS3:   tcxExtensionNamespace,
+		Activities: []tcxActivity{activity},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("生成 TCX 失败: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWriteTrackTCX(t *testing.T) {
+	track := lapTrack()
+	var buf bytes.Buffer
+	if err := WriteTrackTCX(&buf, "周末长跑", track); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+
+	var doc struct {
+		XMLName    xml.Name `xml:"http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2 TrainingCenterDatabase"`
+		Activities []struct {
+			Sport string `xml:"Sport,attr"`
+			Notes string `xml:"Notes"`
+			Laps  []struct {
+				StartTime        string  `xml:"StartTime,attr"`
+				TotalTimeSeconds float64 `xml:"TotalTimeSeconds"`
+				DistanceMeters   float64 `xml:"DistanceMeters"`
+				Trackpoints      []struct {
+					Time  string  `xml:"Time"`
+					Speed float64 `xml:"Extensions>TPX>Speed"`
+				} `xml:"Track>Trackpoint"`
+				AvgSpeed float64 `xml:"Extensions>LX>AvgSpeed"`
+			} `xml:"Lap"`
+		} `xml:"Activities>Activity"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("解析 TCX 失败: %v", err)
+	}
+	if len(doc.Activities) != 1 || doc.Activities[0].Sport != "Running" || doc.Activities[0].Notes != "周末长跑" {
+		t.Fatalf("活动 = %+v", doc.Activities)
+	}
+
+	// 每圈一个 Lap，计时扣除暂停
+	laps := doc.Activities[0].Laps
+	if len(laps) != 2 {
+		t.Fatalf("Lap 数 = %d，期望 2", len(laps))
+	}
+	counts := []int{11, 11}
+	starts := []time.Time{track[0].Time, track[10].Time}
+	total := 0
+	for i, lap := range laps {
+		if lap.StartTime != starts[i].Format(time.RFC3339) || lap.TotalTimeSeconds != 10 || math.Abs(lap.DistanceMeters-30) > 1e-9 || math.Abs(lap.AvgSpeed-3) > 1e-9 {
+			t.Errorf("第 %d 圈 = 开始 %s 计时 %v 距离 %v 均速 %v", i+1, lap.StartTime, lap.TotalTimeSeconds, lap.DistanceMeters, lap.AvgSpeed)
+		}
+		if len(lap.Trackpoints) != counts[i] {
+			t.Errorf("第 %d 圈 %d 个轨迹点，期望 %d 个", i+1, len(lap.Trackpoints), counts[i])
+		}
+		for _, p := range lap.Trackpoints {
+			if math.Abs(p.Speed-3) > 1e-9 {
+				t.Errorf("第 %d 圈 %s 速度 %v m/s，期望 3", i+1, p.Time, p.Speed)
+			}
+		}
+		total += len(lap.Trackpoints)
+	}
+	if total != len(track) {
+		t.Errorf("共 %d 个轨迹点，期望 %d 个", total, len(track))
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// WorkoutStepKind 训练阶段种类
+type WorkoutStepKind string
+
+const (
+	WorkoutWarmUp   WorkoutStepKind = "warmup"
+	WorkoutWork     WorkoutStepKind = "work"
+	WorkoutRecovery WorkoutStepKind = "recovery"
+	WorkoutCoolDown WorkoutStepKind = "cooldown"
+)
+
+// WorkoutStep 训练中的一段，按距离或时间结束
+type WorkoutStep struct {
+	Distance float64 `json:"distance,omitempty"` // m，与 Duration 二选一
+	Duration float64 `json:"duration,omitempty"` // s
+	Speed    float64 `json:"speed"`              // km/h
+}
+
+// Workout 间歇训练：热身，Repeats 组（高强度 + 恢复），放松
+//
+// 训练在任意路线上执行，路线跑完后自动从头循环，全部阶段结束即完成跑步；
+// 训练期间忽略循环圈数和路线点自带的速度，SetSpeed 仍按比例缩放各阶段速度。
+type Workout struct {
+	Name     string       `json:"name,omitempty"`
+	WarmUp   *WorkoutStep `json:"warmUp,omitempty"`
+	Repeats  int          `json:"repeats"`
+	Work     WorkoutStep  `json:"work"`
+	Recovery *WorkoutStep `json:"recovery,omitempty"`
+	CoolDown *WorkoutStep `json:"coolDown,omitempty"`
+}
+
+// IntervalStatus 当前训练阶段，随 running:interval 事件发送
+type IntervalStatus struct {
+	Index    int             `json:"index"` // 阶段序号，从 0 开始
+	Total    int             `json:"total"` // 阶段总数
+	Kind     WorkoutStepKind `json:"kind"`
+	Repeat   int             `json:"repeat"`             // 第几组，从 1 开始，热身和放松为 0
+	Repeats  int             `json:"repeats"`            // 总组数
+	Distance float64         `json:"distance,omitempty"` // 目标距离 m
+	Duration float64         `json:"duration,omitempty"` // 目标时间 s
+	Speed    float64         `json:"speed"`              // 目标速度 km/h
+}
+
+// Name 阶段名称，如 "热身"、"第 2/6 组高强度"
+func (i IntervalStatus) Name() string {
+	name := map[WorkoutStepKind]string{
+		WorkoutWarmUp: "热身", WorkoutWork: "高强度", WorkoutRecovery: "恢复", WorkoutCoolDown: "放松",
+	}[i.Kind]
+	if i.Repeat > 0 {
+		name = fmt.Sprintf("第 %d/%d 组%s", i.Repeat, i.Repeats, name)
+	}
+	return name
+}
+
+// Validate 检查训练设置
+func (w Workout) Validate() error {
+	if w.Repeats < 1 {
+		return fmt.Errorf("训练组数至少为 1")
+	}
+	check := func(name string, s *WorkoutStep) error {
+		if s == nil {
+			return nil
+		}
+		if (s.Distance > 0) == (s.Duration > 0) {
+			return fmt.Errorf("%s必须指定距离或时间中的一项", name)
+		}
+		if s.Distance < 0 || s.Duration < 0 {
+			return fmt.Errorf("%s的距离和时间不能为负数", name)
+		}
+		if s.Speed <= 0 {
+			return fmt.Errorf("%s的速度必须大于 0", name)
+		}
+		return nil
+	}
+	for _, c := range []struct {
+		name string
+		step *WorkoutStep
+	}{{"热身", w.WarmUp}, {"高强度段", &w.Work}, {"恢复段", w.Recovery}, {"放松", w.CoolDown}} {
+		if err := check(c.name, c.step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// phases 将训练展开为按顺序执行的阶段
+func (w Workout) phases() []IntervalStatus {
+	var phases []IntervalStatus
+	add := func(kind WorkoutStepKind, repeat int, s *WorkoutStep) {
+		if s == nil {
+			return
+		}
+		phases = append(phases, IntervalStatus{
+			Kind: kind, Repeat: repeat, Repeats: w.Repeats,
+			Distance: s.Distance, Duration: s.Duration, Speed: s.Speed,
+		})
+	}
+	add(WorkoutWarmUp, 0, w.WarmUp)
+	for i := 1; i <= w.Repeats; i++ {
+		add(WorkoutWork, i, &w.Work)
+		add(WorkoutRecovery, i, w.Recovery)
+	}
+	add(WorkoutCoolDown, 0, w.CoolDown)
+	for i := range phases {
+		phases[i].Index = i
+		phases[i].Total = len(phases)
+	}
+	return phases
+}
+
+// workoutProgress 训练执行进度
+type workoutProgress struct {
+	phases          []IntervalStatus
+	index           int
+	startDistanceKM float64       // 当前阶段开始时的累计距离
+	elapsed         time.Duration // 当前阶段已用时间，含停留，不含暂停
+	lastDistanceKM  float64       // 上一次 advance 时的累计距离
+}
+
+func newWorkoutProgress(w *Workout) *workoutProgress {
+	if w == nil {
+		return nil
+	}
+	return &workoutProgress{phases: w.phases()}
+}
+
+// current 当前阶段，训练结束后返回 nil
+func (w *workoutProgress) current() *IntervalStatus {
+	if w == nil || w.index >= len(w.phases) {
+		return nil
+	}
+	phase := w.phases[w.index]
+	return &phase
+}
+
+// advance 记录经过的时间和当前累计距离，当前阶段达到目标时进入下一阶段并返回 true
+//
+// 一步内越过阶段终点的部分按本步的平均速度折算为距离和时间，计入下一阶段；
+// 一步越过多个阶段时依次结算。
+func (w *workoutProgress) advance(dt time.Duration, distanceKM float64) bool {
+	if w.current() == nil {
+		return false
+	}
+	stepKM := distanceKM - w.lastDistanceKM
+	w.lastDistanceKM = distanceKM
+	w.elapsed += dt
+
+	changed := false
+	for phase := w.current(); phase != nil; phase = w.current() {
+		runKM := distanceKM - w.startDistanceKM
+		var extraKM float64
+		var extraTime time.Duration
+		switch {
+		case phase.Distance > 0:
+			extraKM = runKM - phase.Distance/1000
+			if extraKM < 0 {
+				return changed
+			}
+			if stepKM > 0 {
+				extraTime = time.Duration(float64(dt) * min(extraKM/stepKM, 1))
+			}
+		case phase.Duration > 0:
+			extraTime = w.elapsed - time.Duration(phase.Duration*float64(time.Second))
+			if extraTime < 0 {
+				return changed
+			}
+			if dt > 0 {
+				extraKM = stepKM * min(float64(extraTime)/float64(dt), 1)
+			}
+		}
+		w.index++
+		w.startDistanceKM = distanceKM - extraKM
+		w.elapsed = min(extraTime, w.elapsed)
+		changed = true
+	}
+	return changed
+}
+
+// finished 全部阶段是否已完成
+func (w *workoutProgress) finished() bool {
+	return w != nil && w.index >= len(w.phases)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWorkoutAdvanceCarriesOvershoot(t *testing.T) {
+	workout := &Workout{
+		Repeats:  1,
+		Work:     WorkoutStep{Distance: 400, Speed: 18},
+		Recovery: &WorkoutStep{Duration: 60, Speed: 8},
+		CoolDown: &WorkoutStep{Distance: 100, Speed: 8},
+	}
+	// 第一步 10 秒跑了 450 m，越过 400 m 的 50 m 按本步平均速度折算为时间
+	carried := 10 * time.Second * 50 / 450
+	// 恢复段从 carried 开始计时，到第三步超出 60 秒的部分同样是 carried，对应第三步 100 m 中的一部分
+	extra := carried
+
+	steps := []struct {
+		name       string
+		dt         time.Duration
+		distanceKM float64
+		changed    bool
+		index      int
+		startKM    float64
+		elapsed    time.Duration
+	}{
+		{"距离阶段越界", 10 * time.Second, 0.45, true, 1, 0.4, carried},
+		{"时间阶段未到", 50 * time.Second, 0.5, false, 1, 0.4, carried + 50*time.Second},
+		{"时间阶段越界", 10 * time.Second, 0.6, true, 2, 0.6 - 0.1*extra.Seconds()/10, extra},
+	}
+
+	progress := newWorkoutProgress(workout)
+	for _, s := range steps {
+		if got := progress.advance(s.dt, s.distanceKM); got != s.changed {
+			t.Fatalf("%s: changed = %v，期望 %v", s.name, got, s.changed)
+		}
+		if progress.index != s.index {
+			t.Fatalf("%s: index = %d，期望 %d", s.name, progress.index, s.index)
+		}
+		if math.Abs(progress.startDistanceKM-s.startKM) > 1e-9 {
+			t.Errorf("%s: 阶段起点 = %f km，期望 %f km", s.name, progress.startDistanceKM, s.startKM)
+		}
+		if d := progress.elapsed - s.elapsed; d < -time.Microsecond || d > time.Microsecond {
+			t.Errorf("%s: 阶段已用时间 = %s，期望 %s", s.name, progress.elapsed, s.elapsed)
+		}
+	}
+}
+
+func TestWorkoutAdvanceCrossesSeveralPhases(t *testing.T) {
+	workout := &Workout{
+		Repeats:  2,
+		Work:     WorkoutStep{Distance: 100, Speed: 18},
+		Recovery: &WorkoutStep{Distance: 100, Speed: 8},
+	}
+	progress := newWorkoutProgress(workout)
+
+	// 一步跑完 250 m，越过两个阶段，剩余 50 m 计入第 2 组高强度
+	if !progress.advance(50*time.Second, 0.25) {
+		t.Fatal("应进入下一阶段")
+	}
+	if progress.index != 2 {
+		t.Fatalf("index = %d，期望 2", progress.index)
+	}
+	if math.Abs(progress.startDistanceKM-0.2) > 1e-9 {
+		t.Errorf("起点 = %f，期望 0.2", progress.startDistanceKM)
+	}
+	if d := progress.elapsed - 10*time.Second; d < -time.Microsecond || d > time.Microsecond {
+		t.Errorf("已用时间 = %s，期望 10s", progress.elapsed)
+	}
+
+	progress.advance(20*time.Second, 0.5)
+	if !progress.finished() {
+		t.Errorf("全部阶段应已完成，当前 index = %d", progress.index)
+	}
+}
+
+func TestWorkoutRunPhasesAndEvents(t *testing.T) {
+	workout := &Workout{
+		WarmUp:   &WorkoutStep{Distance: 100, Speed: 6},
+		Repeats:  2,
+		Work:     WorkoutStep{Distance: 200, Speed: 15},
+		Recovery: &WorkoutStep{Duration: 30, Speed: 6},
+		CoolDown: &WorkoutStep{Distance: 100, Speed: 6},
+	}
+	run := newManualRun(t)
+	if err := run.service.SetWorkout(workout); err != nil {
+		t.Fatalf("设置训练失败: %v", err)
+	}
+	// 训练期间忽略圈数，路线跑完后继续循环
+	run.service.SetLoopCount(1)
+	run.start(squareRoute(), 8)
+	status, _ := run.finish(20 * time.Minute)
+
+	want := []struct {
+		kind   WorkoutStepKind
+		repeat int
+	}{
+		{WorkoutWarmUp, 0}, {WorkoutWork, 1}, {WorkoutRecovery, 1}, {WorkoutWork, 2}, {WorkoutRecovery, 2}, {WorkoutCoolDown, 0},
+	}
+	events := run.events.named(EventRunningInterval)
+	if len(events) != len(want) {
+		t.Fatalf("训练阶段事件 %d 个，期望 %d", len(events), len(want))
+	}
+	for i, data := range events {
+		interval := data.(IntervalStatus)
+		if interval.Index != i || interval.Total != len(want) || interval.Kind != want[i].kind || interval.Repeat != want[i].repeat {
+			t.Errorf("第 %d 个阶段事件 = %+v", i, interval)
+		}
+	}
+
+	for _, p := range run.events.positions() {
+		if p.Interval != nil && p.Speed != p.Interval.Speed {
+			t.Fatalf("阶段 %d 的速度 = %.2f km/h，期望 %.2f", p.Interval.Index, p.Speed, p.Interval.Speed)
+		}
+	}
+
+	if status.CompletedBy != EndByWorkout {
+		t.Errorf("结束原因 = %q，期望 %q", status.CompletedBy, EndByWorkout)
+	}
+	if status.CurrentLoop < 2 {
+		t.Errorf("圈数 = %d，训练应在路线跑完后继续循环", status.CurrentLoop)
+	}
+	// 热身 100 m + 2×(200 m + 30 秒 × 6 km/h) + 放松 100 m
+	if math.Abs(status.Distance*1000-700) > 2 {
+		t.Errorf("总距离 = %.1f m，期望 700 m", status.Distance*1000)
+	}
+}
+
+func TestWorkoutValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		workout Workout
+		ok      bool
+	}{
+		{"有效", Workout{Repeats: 3, Work: WorkoutStep{Distance: 400, Speed: 15}}, true},
+		{"组数为 0", Workout{Repeats: 0, Work: WorkoutStep{Distance: 400, Speed: 15}}, false},
+		{"同时指定距离和时间", Workout{Repeats: 1, Work: WorkoutStep{Distance: 400, Duration: 60, Speed: 15}}, false},
+		{"未指定距离和时间", Workout{Repeats: 1, Work: WorkoutStep{Speed: 15}}, false},
+		{"速度为 0", Workout{Repeats: 1, Work: WorkoutStep{Duration: 60}}, false},
+		{"恢复段无效", Workout{Repeats: 1, Work: WorkoutStep{Duration: 60, Speed: 15}, Recovery: &WorkoutStep{Duration: -30, Speed: 6}}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.workout.Validate(); (err == nil) != c.ok {
+				t.Errorf("Validate() = %v", err)
+			}
+		})
+	}
+}